@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/keystore"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/keywrap"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// A backup holds every key record of the key store sealed with AES-GCM under a random
+// backup key. The backup key is sealed either under a passphrase, derived with scrypt,
+// or for the public key of a recipient certificate, wrapped like keyExchange secrets.
+type keyBackup struct {
+	Version int                   `json:"version"`
+	Params  keystore.MasterParams `json:"params"` // passphrase backups
+	// Recipient is the common name of the certificate the backup is for
+	Recipient  string `json:"recipient,omitempty"`
+	WrappedKey []byte `json:"wrappedKey"`
+	Records    []byte `json:"records"`
+}
+
+const backupCheck = "backup"
+
+// backupConfig says how a backup is sealed
+type backupConfig struct {
+	Cert           string
+	MSPKey         string
+	PassphraseFile string
+}
+
+// backupFlags registers the key backup command line flags, call before flag.Parse
+func backupFlags() *backupConfig {
+	b := &backupConfig{}
+	flag.StringVar(&b.Cert, "backup-cert", "", "encrypt the export for the public key of this certificate instead of a passphrase")
+	flag.StringVar(&b.MSPKey, "backup-msp-key", "", "private key (or keystore directory) to import a backup made for a certificate")
+	flag.StringVar(&b.PassphraseFile, "backup-passphrase-file", "", "file holding the backup passphrase, BACKUP_PASSPHRASE otherwise")
+	return b
+}
+
+func (b *backupConfig) passphrase() ([]byte, error) {
+	if b.PassphraseFile != "" {
+		data, err := ioutil.ReadFile(b.PassphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strings.TrimRight(string(data), "\r\n")), nil
+	}
+	if p := os.Getenv("BACKUP_PASSPHRASE"); p != "" {
+		return []byte(p), nil
+	}
+	return nil, errors.New("a backup needs -backup-passphrase-file, BACKUP_PASSPHRASE or a certificate")
+}
+
+// export writes an encrypted backup of the key store to file
+func (b *backupConfig) export(file string) error {
+	records, err := allRecords()
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	backup := keyBackup{Version: 1}
+	if backup.Records, err = keystore.Seal(key, backupCheck, plain); err != nil {
+		return err
+	}
+
+	if b.Cert != "" {
+		pub, cn, err := loadCertificateKey(b.Cert)
+		if err != nil {
+			return err
+		}
+		backup.Recipient = cn
+		if backup.WrappedKey, err = keywrap.Wrap(pub, key); err != nil {
+			return err
+		}
+	} else {
+		passphrase, err := b.passphrase()
+		if err != nil {
+			return err
+		}
+		backup.Params = keystore.MasterParams{Salt: make([]byte, 16), N: 1 << 15, R: 8, P: 1}
+		if _, err := rand.Read(backup.Params.Salt); err != nil {
+			return err
+		}
+		kek, err := scrypt.Key(passphrase, backup.Params.Salt, backup.Params.N, backup.Params.R, backup.Params.P, 32)
+		if err != nil {
+			return err
+		}
+		if backup.WrappedKey, err = keystore.Seal(kek, backupCheck, key); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		return err
+	}
+	fmt.Println("exported", len(records), "keys to", file)
+	return nil
+}
+
+// importFile merges the encrypted backup in file into the key store
+func (b *backupConfig) importFile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var backup keyBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return errors.Wrapf(err, "%s is not a key backup", file)
+	}
+
+	var key []byte
+	if backup.Recipient != "" {
+		if b.MSPKey == "" {
+			return errors.Errorf("the backup is for %s, its private key is needed (-backup-msp-key)", backup.Recipient)
+		}
+		priv, err := loadMSPKey(b.MSPKey)
+		if err != nil {
+			return err
+		}
+		if key, err = keywrap.Unwrap(priv, backup.WrappedKey); err != nil {
+			return errors.Wrap(err, "the backup is not for this key")
+		}
+	} else {
+		passphrase, err := b.passphrase()
+		if err != nil {
+			return err
+		}
+		p := backup.Params
+		kek, err := scrypt.Key(passphrase, p.Salt, p.N, p.R, p.P, 32)
+		if err != nil {
+			return err
+		}
+		if key, err = keystore.Unseal(kek, backupCheck, backup.WrappedKey); err != nil {
+			return errors.New("wrong backup passphrase")
+		}
+	}
+
+	plain, err := keystore.Unseal(key, backupCheck, backup.Records)
+	if err != nil {
+		return errors.Wrap(err, "corrupt backup")
+	}
+	var records []keyRecord
+	if err := json.Unmarshal(plain, &records); err != nil {
+		return errors.Wrap(err, "corrupt backup")
+	}
+	imported := 0
+	for _, r := range records {
+		ok, err := importRecord(r)
+		if err != nil {
+			return err
+		}
+		if ok {
+			imported++
+		}
+	}
+	fmt.Println("imported", imported, "of", len(records), "keys from", file)
+	return nil
+}
+
+// allRecords returns every key record of the key store
+func allRecords() ([]keyRecord, error) {
+	names, err := keyStore.List()
+	if err != nil {
+		return nil, err
+	}
+	var records []keyRecord
+	for _, name := range names {
+		if !strings.HasPrefix(name, recordPrefix) {
+			continue
+		}
+		r, err := getRecord(strings.TrimPrefix(name, recordPrefix))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// importRecord stores r unless the key store already has the same or a newer version of it
+func importRecord(r keyRecord) (bool, error) {
+	if old, err := getRecord(r.ref()); err == nil && old.Version >= r.Version {
+		return false, nil
+	}
+	return true, putRecord(r)
+}
+
+// loadCertificateKey returns the public key and common name of a PEM certificate
+func loadCertificateKey(path string) (*ecdsa.PublicKey, string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, "", errors.Errorf("%s is not PEM", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, "", err
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, "", errors.Errorf("%s does not hold an ECDSA key", path)
+	}
+	return pub, cert.Subject.CommonName, nil
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	fab "github.com/hyperledger/fabric-sdk-go/api/apifabclient"
+	chmgmt "github.com/hyperledger/fabric-sdk-go/api/apitxn/chmgmtclient"
+	resmgmt "github.com/hyperledger/fabric-sdk-go/api/apitxn/resmgmtclient"
+	packager "github.com/hyperledger/fabric-sdk-go/pkg/fabric-client/ccpackager/gopackager"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/pkg/errors"
+)
+
+// pollInterval is how often bootstrap asks the peers whether a step is done
+const pollInterval = time.Second
+
+// bootstrapCommand implements
+//
+//	fabric_torrent bootstrap [flags]
+//
+// It sets up what the manifest describes: it creates the channels, has the peers of
+// their orgs join them and installs and instantiates the chaincodes on them. Every step
+// is checked on the peers first and skipped when done, so bootstrap can be run again
+// after a failure, and is only over once every peer shows it.
+//
+// A chaincode instantiated at another version than the manifest's is only upgraded with
+// -upgrade: the new version is installed on every peer of the channel, then the
+// chaincode upgraded with the args and policy of the manifest. Its Init migrates the
+// state of the previous version.
+func bootstrapCommand(args []string) error {
+	network := fabricclient.Config{}
+	flag.StringVar(&network.ConfigFile, "config", "config_test.yaml", "SDK configuration of the network")
+	flag.StringVar(&network.Manifest, "manifest", "network.yaml", "channels, orgs and chaincodes to set up")
+	timeout := flag.Duration("timeout", 2*time.Minute, "how long a step may take to show on every peer")
+	upgrade := flag.Bool("upgrade", false, "upgrade the chaincodes instantiated at another version than the manifest's")
+	flag.CommandLine.Parse(args)
+
+	c, err := fabricclient.New(network)
+	if err != nil {
+		return err
+	}
+	manifest := c.Manifest
+	if manifest == nil {
+		return errors.New("-manifest names no manifest")
+	}
+	// a policy the peers cannot satisfy would only show when the chaincode is invoked
+	for _, cc := range manifest.Chaincodes {
+		if _, err := c.Endorsers(cc.Name); err != nil {
+			return err
+		}
+	}
+	b := &bootstrapper{client: c, manifest: manifest, timeout: *timeout, upgrade: *upgrade, admins: make(map[string]*orgAdmin)}
+	for i := range manifest.Channels {
+		if err := b.setupChannel(&manifest.Channels[i]); err != nil {
+			return err
+		}
+	}
+	for i := range manifest.Chaincodes {
+		if err := b.deployChaincode(&manifest.Chaincodes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bootstrapper sets up a manifest as the admins of its orgs
+type bootstrapper struct {
+	client   *fabricclient.Client
+	manifest *fabricclient.Manifest
+	timeout  time.Duration
+	upgrade  bool
+	admins   map[string]*orgAdmin
+}
+
+// orgAdmin is the admin of an org and the peers of the org it manages
+type orgAdmin struct {
+	fabricclient.OrgManifest
+	client resmgmt.ResourceMgmtClient
+	peers  []fab.Peer
+}
+
+// admin returns the admin of org, set up on first use
+func (b *bootstrapper) admin(org string) (*orgAdmin, error) {
+	if admin, ok := b.admins[org]; ok {
+		return admin, nil
+	}
+	client, err := b.client.SDK.NewClient(fabsdk.WithUser(b.manifest.Admin), fabsdk.WithOrg(org)).ResourceMgmt()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create new resource management client for %s", org)
+	}
+	peers, err := b.client.Peers(org)
+	if err != nil {
+		return nil, err
+	}
+	admin := &orgAdmin{OrgManifest: *b.manifest.Org(org), client: client, peers: peers}
+	b.admins[org] = admin
+	return admin, nil
+}
+
+// converge runs act until done reports the step it takes over, then polls done until
+// every peer shows it. act is nil for steps the peers take on their own.
+func (b *bootstrapper) converge(what string, done func() (bool, error), act func() error) error {
+	deadline := time.Now().Add(b.timeout)
+	acted := act == nil
+	for {
+		ok, err := done()
+		if err == nil && ok {
+			return nil
+		}
+		if err == nil && !acted {
+			if err = act(); err == nil {
+				acted = true
+			}
+		}
+		if time.Now().After(deadline) {
+			if err == nil {
+				err = errors.Errorf("not done after %s", b.timeout)
+			}
+			return errors.Wrap(err, what)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// setupChannel creates channel unless a peer joined it already and has the peers of its
+// orgs join it
+func (b *bootstrapper) setupChannel(channel *fabricclient.ChannelManifest) error {
+	pending := make(map[string][]fab.Peer)
+	joined := 0
+	for _, org := range channel.Orgs {
+		admin, err := b.admin(org)
+		if err != nil {
+			return err
+		}
+		peers, err := admin.without(admin.peers, admin.joined(channel.Name))
+		if err != nil {
+			return err
+		}
+		joined += len(admin.peers) - len(peers)
+		if len(peers) > 0 {
+			pending[org] = peers
+		}
+	}
+	if len(pending) == 0 {
+		fmt.Println("channel", channel.Name+": joined by every peer already")
+		return nil
+	}
+
+	// the channel may exist without any peer in it, joining tells
+	var createErr error
+	if joined == 0 {
+		if createErr = b.createChannel(channel); createErr == nil {
+			fmt.Println("channel", channel.Name+": created")
+		}
+	}
+
+	for _, org := range channel.Orgs {
+		admin, peers := b.admins[org], pending[org]
+		if len(peers) == 0 {
+			continue
+		}
+		// joining fetches the genesis block, it fails until the orderer created the channel
+		err := b.converge("peers of "+org+" joining "+channel.Name,
+			func() (bool, error) {
+				remaining, err := admin.without(peers, admin.joined(channel.Name))
+				return len(remaining) == 0, err
+			},
+			func() error {
+				remaining, err := admin.without(peers, admin.joined(channel.Name))
+				if err != nil {
+					return err
+				}
+				return admin.client.JoinChannel(channel.Name, resmgmt.WithTargets(remaining...))
+			})
+		if err != nil && createErr != nil {
+			return errors.Wrapf(err, "creating the channel failed: %s", createErr)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println("channel", channel.Name+":", len(peers), "peers of", org, "joined")
+	}
+	return nil
+}
+
+func (b *bootstrapper) createChannel(channel *fabricclient.ChannelManifest) error {
+	chMgmtClient, err := b.client.SDK.NewClient(fabsdk.WithUser(b.manifest.Admin), fabsdk.WithOrg(b.manifest.OrdererOrg)).ChannelMgmt()
+	if err != nil {
+		return err
+	}
+	creator, err := b.client.Identity(channel.Orgs[0], b.manifest.Admin)
+	if err != nil {
+		return err
+	}
+	req := chmgmt.SaveChannelRequest{ChannelID: channel.Name, ChannelConfig: channel.Tx, SigningIdentity: creator}
+	return chMgmtClient.SaveChannel(req)
+}
+
+// deployChaincode installs cc on the peers of the orgs of its channel missing it and
+// instantiates it, or upgrades it from the version instantiated
+func (b *bootstrapper) deployChaincode(cc *fabricclient.ChaincodeManifest) error {
+	channel := b.manifest.Channel(cc.Channel)
+	instantiator, err := b.admin(channel.Orgs[0])
+	if err != nil {
+		return err
+	}
+	version, err := instantiator.instantiated(channel.Name, cc.Name, instantiator.peers[0])
+	if err != nil {
+		return err
+	}
+	if version != "" && version != cc.Version && !b.upgrade {
+		return errors.Errorf("%s is instantiated on %s at version %s, not %s, run with -upgrade to upgrade it", cc.Name, channel.Name, version, cc.Version)
+	}
+
+	var ccPkg *fab.CCPackage
+	for _, org := range channel.Orgs {
+		admin, err := b.admin(org)
+		if err != nil {
+			return err
+		}
+		peers, err := admin.without(admin.peers, admin.installed(cc.Name, cc.Version))
+		if err != nil {
+			return err
+		}
+		if len(peers) == 0 {
+			continue
+		}
+		previous, err := admin.versions(cc.Name, peers)
+		if err != nil {
+			return err
+		}
+		if ccPkg == nil {
+			if ccPkg, err = packager.NewCCPackage(cc.Path, b.manifest.Gopath); err != nil {
+				return errors.Wrapf(err, "unable to package %s", cc.Name)
+			}
+		}
+		req := resmgmt.InstallCCRequest{Name: cc.Name, Path: cc.Path, Version: cc.Version, Package: ccPkg}
+		err = b.converge("install of "+cc.Name+" on the peers of "+org,
+			func() (bool, error) {
+				remaining, err := admin.without(peers, admin.installed(cc.Name, cc.Version))
+				return len(remaining) == 0, err
+			},
+			func() error {
+				responses, err := admin.client.InstallCC(req, resmgmt.WithTargets(peers...))
+				if err != nil {
+					return err
+				}
+				for _, response := range responses {
+					if response.Err != nil {
+						return errors.Wrapf(response.Err, "install on %s failed", response.Target)
+					}
+				}
+				return nil
+			})
+		if err != nil {
+			return err
+		}
+		fmt.Println("chaincode", cc.Name, cc.Version+": installed on", len(peers), "peers of", org+versionsNote(previous))
+	}
+
+	if version == cc.Version {
+		fmt.Println("chaincode", cc.Name, cc.Version+": instantiated on", channel.Name, "already")
+		return nil
+	}
+	policy, err := cc.Policy.Envelope(b.manifest)
+	if err != nil {
+		return err
+	}
+
+	if version == "" {
+		req := resmgmt.InstantiateCCRequest{Name: cc.Name, Path: cc.Path, Version: cc.Version, Args: cc.InitArgs(), Policy: policy}
+		err = b.converge("instantiation of "+cc.Name+" on "+channel.Name,
+			func() (bool, error) {
+				return b.instantiatedEverywhere(channel, cc)
+			},
+			func() error {
+				return instantiator.client.InstantiateCC(channel.Name, req)
+			})
+		if err != nil {
+			return err
+		}
+		fmt.Println("chaincode", cc.Name, cc.Version+": instantiated on", channel.Name)
+		return nil
+	}
+
+	req := resmgmt.UpgradeCCRequest{Name: cc.Name, Path: cc.Path, Version: cc.Version, Args: cc.InitArgs(), Policy: policy}
+	err = b.converge("upgrade of "+cc.Name+" on "+channel.Name+" from version "+version,
+		func() (bool, error) {
+			return b.instantiatedEverywhere(channel, cc)
+		},
+		func() error {
+			return instantiator.client.UpgradeCC(channel.Name, req)
+		})
+	if err != nil {
+		return err
+	}
+	fmt.Println("chaincode", cc.Name, cc.Version+": upgraded on", channel.Name, "from version", version)
+	return nil
+}
+
+// instantiatedEverywhere reports whether every peer of channel runs version of cc
+func (b *bootstrapper) instantiatedEverywhere(channel *fabricclient.ChannelManifest, cc *fabricclient.ChaincodeManifest) (bool, error) {
+	for _, org := range channel.Orgs {
+		admin := b.admins[org]
+		for _, target := range admin.peers {
+			version, err := admin.instantiated(channel.Name, cc.Name, target)
+			if err != nil || version != cc.Version {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// without returns the peers has does not report true for
+func (a *orgAdmin) without(peers []fab.Peer, has func(fab.Peer) (bool, error)) ([]fab.Peer, error) {
+	var missing []fab.Peer
+	for _, target := range peers {
+		ok, err := has(target)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			missing = append(missing, target)
+		}
+	}
+	return missing, nil
+}
+
+// joined reports whether a peer joined channel
+func (a *orgAdmin) joined(channel string) func(fab.Peer) (bool, error) {
+	return func(target fab.Peer) (bool, error) {
+		response, err := a.client.QueryChannels(target)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to query the channels of %s", target.URL())
+		}
+		for _, c := range response.Channels {
+			if c.ChannelId == channel {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// installed reports whether version of chaincode name is installed on a peer
+func (a *orgAdmin) installed(name, version string) func(fab.Peer) (bool, error) {
+	return func(target fab.Peer) (bool, error) {
+		versions, err := a.installedVersions(name, target)
+		return contains(versions, version), err
+	}
+}
+
+// installedVersions returns the versions of chaincode name installed on target
+func (a *orgAdmin) installedVersions(name string, target fab.Peer) ([]string, error) {
+	response, err := a.client.QueryInstalledChaincodes(target)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to query the chaincodes installed on %s", target.URL())
+	}
+	var versions []string
+	for _, cc := range response.Chaincodes {
+		if cc.Name == name {
+			versions = append(versions, cc.Version)
+		}
+	}
+	return versions, nil
+}
+
+// versions returns the versions of chaincode name installed on any of peers
+func (a *orgAdmin) versions(name string, peers []fab.Peer) ([]string, error) {
+	var all []string
+	for _, target := range peers {
+		versions, err := a.installedVersions(name, target)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			if !contains(all, version) {
+				all = append(all, version)
+			}
+		}
+	}
+	return all, nil
+}
+
+// versionsNote tells the versions installed before, if any
+func versionsNote(versions []string) string {
+	if len(versions) == 0 {
+		return ""
+	}
+	return ", which had version " + strings.Join(versions, ", ")
+}
+
+// instantiated returns the version of chaincode name target runs on channel, empty when
+// it is not instantiated
+func (a *orgAdmin) instantiated(channel, name string, target fab.Peer) (string, error) {
+	response, err := a.client.QueryInstantiatedChaincodes(channel, resmgmt.WithTargets(target))
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to query the chaincodes instantiated on %s", target.URL())
+	}
+	for _, cc := range response.Chaincodes {
+		if cc.Name == name {
+			return cc.Version, nil
+		}
+	}
+	return "", nil
+}
+
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package catalog
+
+// Request is the record of a key request stored by the keyExchange chaincode under the
+// id of the requesting transaction
+type Request struct {
+	From             string `json:"from"`
+	To               string `json:"to"`
+	File             string `json:"file"`
+	RequestTime      int64  `json:"requestTime"`
+	ResponseTime     int64  `json:"responseTime"`
+	ConfirmationTime int64  `json:"confirmationTime"`
+	// PubKey is the public key of the requester the secret is wrapped for
+	PubKey         string `json:"pubKey,omitempty"`
+	RevocationTime int64  `json:"revocationTime,omitempty"`
+	// Shares records when each holder delivered its share of the key
+	Shares map[string]int64 `json:"shares,omitempty"`
+}
+
+// ShareConfig says who holds a share of the key of a file and how many recover it
+type ShareConfig struct {
+	Threshold int      `json:"threshold"`
+	Holders   []string `json:"holders"`
+}
+
+// RequestMessage is sent with requestSecret events
+type RequestMessage struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	File        string `json:"file"`
+	TxID        string `json:"tx_id"`
+	RequestTime int64  `json:"requestTime"`
+	PubKey      string `json:"pubKey,omitempty"`
+}
+
+// ResponseMessage is sent with respondSecret events
+type ResponseMessage struct {
+	From         string   `json:"from"`
+	To           []string `json:"to"`
+	File         string   `json:"file"`
+	TxID         []string `json:"tx_id"`
+	Secret       string   `json:"secret"`
+	ResponseTime int64    `json:"responseTime"`
+}
+
+// ShareMessage is sent with respondShare events
+type ShareMessage struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	File      string `json:"file"`
+	TxID      string `json:"tx_id"`
+	Share     string `json:"share"`
+	Delivered int    `json:"delivered"`
+	Threshold int    `json:"threshold"`
+}
+
+// RevocationMessage is sent with revokeSecret events
+type RevocationMessage struct {
+	File           string   `json:"file"`
+	TxID           []string `json:"tx_id"`
+	RevocationTime int64    `json:"revocationTime"`
+}
+
+// ConfirmationMessage is sent with confirmSecret events
+type ConfirmationMessage struct {
+	TxID             string `json:"tx_id"`
+	ConfirmationTime int64  `json:"confirmationTime"`
+}
@@ -0,0 +1,105 @@
+// Package catalog holds the records the myapp and keyExchange chaincodes store and the
+// events they send, so the chaincodes and every client encode them alike.
+//
+// The chaincodes vendor it (make chaincode-vendor), so it only uses the standard library
+// and what the Go release of the chaincode builder provides.
+package catalog
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// FileType is the object type of the composite keys of File records: keyword, name, owner
+const FileType = "File"
+
+// File is the record of a published file stored by the myapp chaincode
+type File struct {
+	Name     string `json:"name"`
+	Hash     string `json:"hash"`
+	Keyword  string `json:"keyword"`
+	Summary  string `json:"summary"`
+	Owner    string `json:"owner"`
+	Locktime int64  `json:"locktime"`
+	// Magnet locates the ciphertext. The key is never stored, owners and share holders
+	// hand it out wrapped through the keyExchange chaincode.
+	Magnet  string `json:"Magnet"`
+	Size    int64  `json:"size"`
+	Info    []byte `json:"info,omitempty"`
+	Cipher  string `json:"cipher,omitempty"`
+	Version int64  `json:"version"`
+	// Access lists, comma separated, who may request the key: a user like
+	// User1@org2.example.com, every user of an org like @org2.example.com, or *.
+	// Empty allows everyone.
+	Access string `json:"access,omitempty"`
+}
+
+// FileKey holds the attributes of the composite key of a File record
+type FileKey struct {
+	Keyword string
+	Name    string
+	Owner   string
+}
+
+// Key returns the composite key attributes of f
+func (f File) Key() FileKey {
+	return FileKey{Keyword: f.Keyword, Name: f.Name, Owner: f.Owner}
+}
+
+// Allows reports whether the access policy of f lets user, a common name like
+// User1@org2.example.com, receive its key. The owner always may.
+func (f File) Allows(user string) bool {
+	if f.Access == "" || f.Owner == user {
+		return true
+	}
+	for _, allowed := range strings.Split(f.Access, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" || allowed == user || (strings.HasPrefix(allowed, "@") && strings.HasSuffix(user, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordKey is a composite key split into its object type and attributes
+type RecordKey struct {
+	ObjectType string   `json:"objectType"`
+	Attributes []string `json:"attributes"`
+}
+
+// Record is an entry of the answer to queryFileRecords
+type Record struct {
+	Key    RecordKey
+	Record File
+}
+
+// DecodeFile decodes a File record, as stored and as sent with createFile and updateFile
+// events
+func DecodeFile(data []byte) (File, error) {
+	var file File
+	err := json.Unmarshal(data, &file)
+	return file, err
+}
+
+// DecodeRecords decodes the answer to queryFileRecords and returns its files
+func DecodeRecords(data []byte) ([]File, error) {
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	files := make([]File, 0, len(records))
+	for _, r := range records {
+		files = append(files, r.Record)
+	}
+	return files, nil
+}
+
+// ParseFileKey returns the attributes of a File composite key, as sent with deleteFile
+// events and in the File of requests. ok is false for another key.
+func ParseFileKey(ckey string) (key FileKey, ok bool) {
+	attributes := strings.Split(strings.Trim(ckey, "\x00"), "\x00")
+	if len(attributes) != 4 || attributes[0] != FileType {
+		return FileKey{}, false
+	}
+	return FileKey{Keyword: attributes[1], Name: attributes[2], Owner: attributes[3]}, true
+}
@@ -0,0 +1,92 @@
+package catalog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFileKey(t *testing.T) {
+	tests := []struct {
+		ckey string
+		key  FileKey
+		ok   bool
+	}{
+		{"\x00File\x00reports\x00a.txt\x00User1@org1.example.com\x00", FileKey{"reports", "a.txt", "User1@org1.example.com"}, true},
+		{"\x00File\x00reports,finance\x00dir\x00User1@org2.example.com\x00", FileKey{"reports,finance", "dir", "User1@org2.example.com"}, true},
+		{"File\x00keywords\x00a.txt\x00User1@org1.example.com", FileKey{"keywords", "a.txt", "User1@org1.example.com"}, true},
+		{"\x00Request\x00reports\x00a.txt\x00User1@org1.example.com\x00", FileKey{}, false},
+		{"\x00File\x00a.txt\x00User1@org1.example.com\x00", FileKey{}, false},
+		{"\x00File\x00reports\x00a.txt\x00User1@org1.example.com\x00tx\x00", FileKey{}, false},
+		{"\x00File\x00", FileKey{}, false},
+		{"a.txt", FileKey{}, false},
+		{"", FileKey{}, false},
+	}
+	for _, test := range tests {
+		key, ok := ParseFileKey(test.ckey)
+		if key != test.key || ok != test.ok {
+			t.Errorf("%q: %+v, %v, expected %+v, %v", test.ckey, key, ok, test.key, test.ok)
+		}
+	}
+}
+
+func TestDecodeRecords(t *testing.T) {
+	a := File{Name: "a.txt", Hash: "00ff", Keyword: "reports", Owner: "User1@org1.example.com", Magnet: "magnet:?xt=urn:btih:00", Size: 10, Info: []byte("d4:name5:a.txte"), Version: 2}
+	b := File{Name: "dir", Keyword: "keywords", Owner: "User1@org2.example.com", Access: "@org1.example.com"}
+	tests := []struct {
+		name  string
+		data  string
+		files []File
+		ok    bool
+	}{
+		{"two records", `[{"Key":{"objectType":"File","attributes":["reports","a.txt","User1@org1.example.com"]},"Record":{"name":"a.txt","hash":"00ff","keyword":"reports","owner":"User1@org1.example.com","Magnet":"magnet:?xt=urn:btih:00","size":10,"info":"ZDQ6bmFtZTU6YS50eHRl","version":2}},` +
+			`{"Key":{"objectType":"File","attributes":["keywords","dir","User1@org2.example.com"]},"Record":{"name":"dir","keyword":"keywords","owner":"User1@org2.example.com","access":"@org1.example.com"}}]`, []File{a, b}, true},
+		{"no record", `[]`, []File{}, true},
+		{"null", `null`, []File{}, true},
+		{"truncated", `[{"Key":{"objectType":"File","attributes":["reports","a.txt"`, nil, false},
+		{"empty", ``, nil, false},
+		{"not a list", `{"Key":{},"Record":{}}`, nil, false},
+		{"corrupt size", `[{"Key":{},"Record":{"name":"a.txt","size":"ten"}}]`, nil, false},
+		{"corrupt info", `[{"Key":{},"Record":{"name":"a.txt","info":"not base64!"}}]`, nil, false},
+	}
+	for _, test := range tests {
+		files, err := DecodeRecords([]byte(test.data))
+		if !test.ok {
+			if err == nil {
+				t.Errorf("%s: decoded %+v", test.name, files)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(files, test.files) {
+			t.Errorf("%s: %+v, expected %+v", test.name, files, test.files)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		access string
+		user   string
+		ok     bool
+	}{
+		{"", "User1@org2.example.com", true},
+		{"*", "User1@org2.example.com", true},
+		{"User1@org2.example.com", "User1@org2.example.com", true},
+		{"User2@org2.example.com, User1@org2.example.com", "User1@org2.example.com", true},
+		{"@org2.example.com", "User1@org2.example.com", true},
+		{"@org2.example.com", "User1@org3.example.com", false},
+		{"User2@org2.example.com", "User1@org2.example.com", false},
+		{"org2.example.com", "User1@org2.example.com", false},
+		// the owner always may
+		{"User2@org2.example.com", "User1@org1.example.com", true},
+	}
+	for _, test := range tests {
+		file := File{Name: "a.txt", Owner: "User1@org1.example.com", Access: test.access}
+		if ok := file.Allows(test.user); ok != test.ok {
+			t.Errorf("%q allows %s: %v, expected %v", test.access, test.user, ok, test.ok)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+)
+
+// infoTimeout is how long a request waits for the info of a torrent to arrive from peers
+var infoTimeout = time.Minute
+
+// catalogServer is a read-only view of the catalog over HTTP:
+//
+//	/owner/<owner>/<name>[/<path in directory>]
+//	/tag/<tag>/<owner>/<name>[/<path in directory>]
+//
+// Fetching a file starts its download, requests its key from the owner and
+// returns the plaintext as it arrives. Range requests are served from any offset.
+// Only the files whose access policy lets user receive the key are shown.
+type catalogServer struct {
+	client   *torrent.Client
+	files    *fileCatalog
+	exchange *keyExchange
+	user     string
+}
+
+func serveCatalog(addr string, s *catalogServer) error {
+	return http.ListenAndServe(addr, s)
+}
+
+func tags(file catalog.File) []string {
+	var ret []string
+	for _, tag := range strings.Split(file.Keyword, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			ret = append(ret, tag)
+		}
+	}
+	return ret
+}
+
+func hasTag(file catalog.File, tag string) bool {
+	for _, t := range tags(file) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueSorted(entries []string) []string {
+	seen := make(map[string]bool)
+	var ret []string
+	for _, e := range entries {
+		if !seen[e] {
+			seen[e] = true
+			ret = append(ret, e)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func writeListing(w http.ResponseWriter, r *http.Request, entries []string) {
+	// listings use relative links, so they must be served from a path ending in a slash
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		return
+	}
+	title := html.EscapeString(r.URL.Path)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<html><head><title>%s</title></head><body><h1>%s</h1><ul>\n", title, title)
+	for _, e := range entries {
+		fmt.Fprintf(w, "<li><a href=\"%s\">%s</a></li>\n", (&url.URL{Path: "./" + e}).String(), html.EscapeString(e))
+	}
+	fmt.Fprint(w, "</ul></body></html>\n")
+}
+
+func (s *catalogServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "read-only", http.StatusMethodNotAllowed)
+		return
+	}
+	var parts []string
+	if p := strings.Trim(r.URL.Path, "/"); p != "" {
+		parts = strings.Split(p, "/")
+	}
+	var all []catalog.File
+	for _, f := range s.files.snapshot() {
+		if f.Allows(s.user) {
+			all = append(all, f)
+		}
+	}
+
+	switch {
+	case len(parts) == 0:
+		writeListing(w, r, []string{"owner/", "tag/"})
+	case parts[0] == "owner" && len(parts) == 1:
+		var owners []string
+		for _, f := range all {
+			owners = append(owners, f.Owner+"/")
+		}
+		writeListing(w, r, uniqueSorted(owners))
+	case parts[0] == "owner" && len(parts) == 2:
+		var names []string
+		for _, f := range all {
+			if f.Owner == parts[1] {
+				names = append(names, f.Name)
+			}
+		}
+		writeListing(w, r, uniqueSorted(names))
+	case parts[0] == "owner":
+		s.serveEntry(w, r, all, "", parts[1], parts[2], strings.Join(parts[3:], "/"))
+	case parts[0] == "tag" && len(parts) == 1:
+		var all_tags []string
+		for _, f := range all {
+			for _, t := range tags(f) {
+				all_tags = append(all_tags, t+"/")
+			}
+		}
+		writeListing(w, r, uniqueSorted(all_tags))
+	case parts[0] == "tag" && len(parts) == 2:
+		var owners []string
+		for _, f := range all {
+			if hasTag(f, parts[1]) {
+				owners = append(owners, f.Owner+"/")
+			}
+		}
+		writeListing(w, r, uniqueSorted(owners))
+	case parts[0] == "tag" && len(parts) == 3:
+		var names []string
+		for _, f := range all {
+			if hasTag(f, parts[1]) && f.Owner == parts[2] {
+				names = append(names, f.Name)
+			}
+		}
+		writeListing(w, r, uniqueSorted(names))
+	case parts[0] == "tag":
+		s.serveEntry(w, r, all, parts[1], parts[2], parts[3], strings.Join(parts[4:], "/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveEntry serves the plaintext of a catalog entry, or the listing of a directory torrent.
+// Under /tag the entry must carry tag.
+func (s *catalogServer) serveEntry(w http.ResponseWriter, r *http.Request, all []catalog.File, tag, owner, name, sub string) {
+	var file catalog.File
+	found := false
+	for _, f := range all {
+		if f.Owner == owner && f.Name == name && (tag == "" || hasTag(f, tag)) {
+			file, found = f, true
+			break
+		}
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	t, err := addTorrent(s.client, file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	select {
+	case <-t.GotInfo():
+	case <-r.Context().Done():
+		return
+	case <-time.After(infoTimeout):
+		http.Error(w, "no peer has the torrent info", http.StatusGatewayTimeout)
+		return
+	}
+	info := t.Info()
+	if info.IsDir() && sub == "" {
+		var entries []string
+		for _, fi := range info.UpvertedFiles() {
+			entries = append(entries, strings.Join(fi.Path, "/"))
+		}
+		writeListing(w, r, uniqueSorted(entries))
+		return
+	}
+
+	key, err := s.exchange.fileKey(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	plaintext, _, err := openPlaintext(t, file.Cipher, key, sub)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer plaintext.Close()
+	budget.touch(t.Name())
+	http.ServeContent(w, r, path.Base(path.Join(name, sub)), time.Time{}, plaintext)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/dustin/go-humanize"
+	"github.com/gosuri/uiprogress"
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/seeding"
+	"github.com/pkg/errors"
+)
+
+// addTorrent adds file to client. When the ledger holds its info dictionary the torrent
+// is added from it, so name, size and pieces are known before any peer is reached.
+// A torrent not added before falls back on the web seeds of the magnet.
+func addTorrent(client *torrent.Client, file catalog.File) (*torrent.Torrent, error) {
+	m, err := metainfo.ParseMagnetURI(file.Magnet)
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := client.Torrent(m.InfoHash); ok {
+		return t, nil
+	}
+	if len(file.Info) == 0 {
+		t, err := client.AddMagnet(file.Magnet)
+		if err != nil {
+			return nil, err
+		}
+		go webSeedFallback(t, webSeeds(file.Magnet))
+		return t, nil
+	}
+	mi := metainfo.MetaInfo{InfoBytes: file.Info}
+	if mi.HashInfoBytes() != m.InfoHash {
+		return nil, errors.Errorf("info of %s does not match its magnet", file.Name)
+	}
+	t, err := client.AddTorrent(&mi)
+	if err != nil {
+		return nil, err
+	}
+	go webSeedFallback(t, webSeeds(file.Magnet))
+	info := t.Info()
+	fmt.Printf("%s: %s in %d pieces\n", info.Name, humanize.Bytes(uint64(info.TotalLength())), info.NumPieces())
+	return t, nil
+}
+
+func download(client * torrent.Client,file catalog.File){
+	if file.Magnet=="" {return}
+	t, err := addTorrent(client, file)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	seeding.ProgressBar(t)
+	go func() {
+		<-t.GotInfo()
+		budget.touch(t.Name())
+		downloadSlots.acquire()
+		defer downloadSlots.release()
+		t.DownloadAll()
+		downloads.watch(t)
+	}()
+	uiprogress.Start()
+}
+
+// watchCatalog keeps files up to date with the createFile, updateFile and deleteFile
+// events of the catalog and downloads the new files and versions subs wants
+func watchCatalog(events <-chan *chclient.CCEvent, torrentClient *torrent.Client, files *fileCatalog, subs *subscriptions) {
+	for ccEvent := range events {
+		if ccEvent.EventName == "deleteFile" {
+			files.remove(string(ccEvent.Payload))
+			continue
+		}
+		fmt.Println("get Magnetlink " + string(ccEvent.Payload))
+		file, err := catalog.DecodeFile(ccEvent.Payload)
+		if err != nil {
+			continue
+		}
+		if ccEvent.EventName == "updateFile" {
+			changed := true
+			for _, old := range files.replace(file) {
+				if old.Magnet == file.Magnet {
+					// only the summary or the access policy changed
+					changed = false
+				} else if m, err := metainfo.ParseMagnetURI(old.Magnet); err == nil && !published(old.Name) {
+					// the previous version of a file of another node is dropped, its
+					// owner decides whether it is still seeded
+					if t, ok := torrentClient.Torrent(m.InfoHash); ok {
+						t.Drop()
+					}
+				}
+			}
+			if changed && subs.wants(file) {
+				download(torrentClient, file)
+			}
+			continue
+		}
+		files.add(file)
+		if subs.wants(file) {
+			download(torrentClient, file)
+		}
+	}
+}
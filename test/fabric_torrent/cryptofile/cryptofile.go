@@ -0,0 +1,139 @@
+// Package cryptofile encrypts and decrypts the entries published by fabric_torrent.
+//
+// A single file is encrypted with a zero IV, its key is unique. The files of a directory
+// share its key, each of them uses the IV derived from its path by FileIV.
+package cryptofile
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// OFB is how files were encrypted at first. Its keystream can only be
+	// produced from the start, so reading at an offset costs the whole prefix.
+	OFB = "aes-256-ofb"
+	// CTR derives the keystream of every block from its counter, so
+	// ciphertext can be decrypted from any offset
+	CTR = "aes-256-ctr"
+)
+
+// Default is used for everything encrypted from now on
+const Default = CTR
+
+// NewStream returns the keystream of cipherName for key and iv positioned at offset.
+// An empty cipherName is OFB, files registered before the cipher was recorded use it.
+func NewStream(cipherName string, key, iv []byte, offset int64) (cipher.Stream, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	var stream cipher.Stream
+	skip := offset
+	switch cipherName {
+	case CTR:
+		counter := make([]byte, aes.BlockSize)
+		copy(counter, iv)
+		addCounter(counter, uint64(offset/aes.BlockSize))
+		stream = cipher.NewCTR(block, counter)
+		skip = offset % aes.BlockSize
+	case OFB, "":
+		stream = cipher.NewOFB(block, iv)
+	default:
+		return nil, errors.New("unknown cipher " + cipherName)
+	}
+	discard := make([]byte, 32*1024)
+	for skip > 0 {
+		n := int64(len(discard))
+		if skip < n {
+			n = skip
+		}
+		stream.XORKeyStream(discard[:n], discard[:n])
+		skip -= n
+	}
+	return stream, nil
+}
+
+// addCounter adds n to the big endian counter block
+func addCounter(counter []byte, n uint64) {
+	for i := len(counter) - 1; i >= 0 && n > 0; i-- {
+		sum := uint64(counter[i]) + n&0xff
+		counter[i] = byte(sum)
+		n = n>>8 + sum>>8
+	}
+}
+
+// FileIV derives the IV of a file inside a published directory. All files of a
+// directory share its key, so each of them needs its own keystream.
+func FileIV(rel string) []byte {
+	sum := sha256.Sum256([]byte(filepath.ToSlash(rel)))
+	return sum[:aes.BlockSize]
+}
+
+// Walk calls fn with the path relative to root of every regular file below root
+func Walk(root string, fn func(rel string) error) error {
+	return filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		return fn(rel)
+	})
+}
+
+// CryptFile encrypts or decrypts in to out, the keystream is the same both ways
+func CryptFile(cipherName string, key, iv []byte, in, out string) error {
+	inFile, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer inFile.Close()
+
+	stream, err := NewStream(cipherName, key, iv, 0)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(out), 0700); err != nil {
+		return err
+	}
+	outFile, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	// Note that this omits any authentication of the encrypted data, an attacker
+	// could flip arbitrary bits in the output.
+	writer := &cipher.StreamWriter{S: stream, W: outFile}
+	_, err = io.Copy(writer, inFile)
+	return err
+}
+
+// CryptEntry encrypts or decrypts the file or directory in to out
+func CryptEntry(cipherName string, key []byte, in, out string) error {
+	fi, err := os.Stat(in)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		// the key is unique for each ciphertext, so a zero IV is fine
+		var iv [aes.BlockSize]byte
+		return CryptFile(cipherName, key, iv[:], in, out)
+	}
+	return Walk(in, func(rel string) error {
+		return CryptFile(cipherName, key, FileIV(rel), filepath.Join(in, rel), filepath.Join(out, rel))
+	})
+}
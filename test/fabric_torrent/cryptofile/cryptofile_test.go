@@ -0,0 +1,123 @@
+package cryptofile
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddCounter(t *testing.T) {
+	tests := []struct {
+		counter string
+		n       uint64
+		result  string
+	}{
+		{"00000000000000000000000000000000", 0, "00000000000000000000000000000000"},
+		{"00000000000000000000000000000000", 1, "00000000000000000000000000000001"},
+		{"000000000000000000000000000000ff", 1, "00000000000000000000000000000100"},
+		{"0000000000000000ffffffffffffffff", 1, "00000000000000010000000000000000"},
+		{"000000000000000000000000000000ff", 0x101, "00000000000000000000000000000200"},
+		{"00000000000000000000000000000001", 0xffffffffffffffff, "00000000000000010000000000000000"},
+		{"ffffffffffffffffffffffffffffffff", 1, "00000000000000000000000000000000"},
+		{"0123456789abcdef0123456789abcdef", 0x0101010101010101, "0123456789abcdef022446688aaccef0"},
+	}
+	for _, test := range tests {
+		counter, _ := hex.DecodeString(test.counter)
+		addCounter(counter, test.n)
+		if got := hex.EncodeToString(counter); got != test.result {
+			t.Errorf("%s + %#x = %s, expected %s", test.counter, test.n, got, test.result)
+		}
+	}
+}
+
+// keystream returns n bytes of the keystream of cipherName starting at offset
+func keystream(t *testing.T, cipherName string, key, iv []byte, offset int64, n int) []byte {
+	stream, err := NewStream(cipherName, key, iv, offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, n)
+	stream.XORKeyStream(out, out)
+	return out
+}
+
+func TestNewStreamOffset(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	ivs := map[string][]byte{
+		"zero iv": make([]byte, aes.BlockSize),
+		// the counter carries into the upper bytes after the first block
+		"carrying iv": append(bytes.Repeat([]byte{0}, 8), bytes.Repeat([]byte{0xff}, 8)...),
+		"file iv":     FileIV("dir/file"),
+	}
+	offsets := []int64{0, 1, aes.BlockSize - 1, aes.BlockSize, aes.BlockSize + 1, 3*aes.BlockSize + 5, 40000}
+	for _, cipherName := range []string{CTR, OFB} {
+		for name, iv := range ivs {
+			whole := keystream(t, cipherName, key, iv, 0, 40100)
+			for _, offset := range offsets {
+				got := keystream(t, cipherName, key, iv, offset, 100)
+				if !bytes.Equal(got, whole[offset:offset+100]) {
+					t.Errorf("%s, %s: keystream at %d differs from the stream read from the start", cipherName, name, offset)
+				}
+			}
+		}
+	}
+}
+
+func TestNewStreamUnknownCipher(t *testing.T) {
+	if _, err := NewStream("aes-256-ecb", make([]byte, 32), make([]byte, aes.BlockSize), 0); err == nil {
+		t.Error("expected an error for an unknown cipher")
+	}
+}
+
+func TestCryptEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cryptofile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"a":       "first file",
+		"sub/b":   "second file",
+		"sub/c/d": "second file",
+	}
+	for rel, content := range files {
+		path := filepath.Join(dir, "plain", rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := bytes.Repeat([]byte{2}, 32)
+	plain, encrypted, decrypted := filepath.Join(dir, "plain"), filepath.Join(dir, "encrypted"), filepath.Join(dir, "decrypted")
+	if err := CryptEntry(Default, key, plain, encrypted); err != nil {
+		t.Fatal(err)
+	}
+	if err := CryptEntry(Default, key, encrypted, decrypted); err != nil {
+		t.Fatal(err)
+	}
+	ciphertexts := make(map[string]string)
+	for rel, content := range files {
+		ciphertext, err := ioutil.ReadFile(filepath.Join(encrypted, rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(ciphertext) == content {
+			t.Errorf("%s is not encrypted", rel)
+		}
+		// files of the same content get their own keystream
+		if other, ok := ciphertexts[string(ciphertext)]; ok {
+			t.Errorf("%s and %s have the same ciphertext", rel, other)
+		}
+		ciphertexts[string(ciphertext)] = rel
+		got, err := ioutil.ReadFile(filepath.Join(decrypted, rel))
+		if err != nil || string(got) != content {
+			t.Errorf("%s decrypts to %q, %v", rel, got, err)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/cryptofile"
+	"github.com/pkg/errors"
+)
+
+// decryptCommand implements
+//
+//	fabric_torrent decrypt [-cipher aes-256-ctr] <hex key> <encrypted path> <output path>
+//
+// The encrypted path is a downloaded file, or the directory of a multi-file torrent.
+// The key is printed by the request command.
+func decryptCommand(args []string) error {
+	cipherName := flag.String("cipher", cryptofile.Default, "cipher of the file as registered on the ledger, aes-256-ofb for files published before ctr")
+	flag.CommandLine.Parse(args)
+	if flag.NArg() != 3 {
+		return errors.New("usage: decrypt [-cipher name] <hex key> <encrypted path> <output path>")
+	}
+	key, err := hex.DecodeString(flag.Arg(0))
+	if err != nil {
+		return errors.Wrap(err, "invalid key")
+	}
+	return cryptofile.CryptEntry(*cipherName, key, flag.Arg(1), flag.Arg(2))
+}
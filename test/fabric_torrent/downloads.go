@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/iplist"
+)
+
+const (
+	downloadActive    = "downloading"
+	downloadCompleted = "completed"
+	downloadFailed    = "failed"
+)
+
+// downloadResult is the definitive outcome of one download
+type downloadResult struct {
+	Name         string
+	InfoHash     string
+	Status       string
+	HashFailures int
+}
+
+// peerBlocklist is an iplist.Ranger holding the peers banned for sending bad
+// pieces too often. It is persisted so bans survive a restart.
+type peerBlocklist struct {
+	mu     sync.RWMutex
+	file   string
+	banned map[string]bool
+}
+
+func loadPeerBlocklist(file string) *peerBlocklist {
+	b := &peerBlocklist{file: file, banned: make(map[string]bool)}
+	if content, err := ioutil.ReadFile(file); err == nil {
+		var ips []string
+		json.Unmarshal(content, &ips)
+		for _, ip := range ips {
+			b.banned[ip] = true
+		}
+	}
+	return b
+}
+
+func (b *peerBlocklist) Lookup(ip net.IP) (iplist.Range, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.banned[ip.String()] {
+		return iplist.Range{}, false
+	}
+	return iplist.Range{First: ip, Last: ip, Description: "sent corrupt pieces"}, true
+}
+
+func (b *peerBlocklist) NumRanges() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.banned)
+}
+
+func (b *peerBlocklist) ban(ip string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.banned[ip] {
+		return
+	}
+	b.banned[ip] = true
+	ips := make([]string, 0, len(b.banned))
+	for ip := range b.banned {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	content, _ := json.Marshal(ips)
+	if err := ioutil.WriteFile(b.file, content, 0600); err != nil {
+		fmt.Println("unable to save banned peers: ", err)
+	}
+}
+
+// downloadManager follows every download until its data is verified, counts pieces
+// that failed their hash check and bans the peers that keep sending them
+type downloadManager struct {
+	client *torrent.Client
+	peers  *peerBlocklist
+	// hash failures of one torrent after which the download is given up
+	maxHashFailures int
+	// corrupt pieces attributed to one peer after which it is banned for good
+	banAfter int
+
+	mu       sync.Mutex
+	status   map[string]*downloadResult
+	offences map[string]int
+	seenBad  map[string]bool
+
+	Results chan downloadResult
+}
+
+func newDownloadManager(client *torrent.Client, peers *peerBlocklist) *downloadManager {
+	return &downloadManager{
+		client:          client,
+		peers:           peers,
+		maxHashFailures: 50,
+		banAfter:        3,
+		status:          make(map[string]*downloadResult),
+		offences:        make(map[string]int),
+		seenBad:         make(map[string]bool),
+		Results:         make(chan downloadResult, 16),
+	}
+}
+
+// hashFailed records a corrupt piece. The torrent client drops the least trusted
+// peer that contributed to it; peers dropped that way banAfter times are banned.
+func (m *downloadManager) hashFailed(r *downloadResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r.HashFailures++
+	for _, ip := range m.client.BadPeerIPs() {
+		if m.seenBad[ip] {
+			continue
+		}
+		m.seenBad[ip] = true
+		m.offences[ip]++
+		if m.offences[ip] >= m.banAfter {
+			fmt.Println("banning peer", ip)
+			m.peers.ban(ip)
+		}
+	}
+}
+
+// forgive lets the client report an already counted peer again once its
+// temporary ban is lifted
+func (m *downloadManager) forgive() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := make(map[string]bool)
+	for _, ip := range m.client.BadPeerIPs() {
+		current[ip] = true
+	}
+	for ip := range m.seenBad {
+		if !current[ip] {
+			delete(m.seenBad, ip)
+		}
+	}
+}
+
+func (m *downloadManager) finish(r *downloadResult, status string) {
+	m.mu.Lock()
+	r.Status = status
+	result := *r
+	m.mu.Unlock()
+	fmt.Printf("%s %s (%d hash failures)\n", result.Name, result.Status, result.HashFailures)
+	m.Results <- result
+}
+
+// watch follows t until all of its pieces are verified or too many of them failed
+func (m *downloadManager) watch(t *torrent.Torrent) {
+	r := &downloadResult{Name: t.Name(), InfoHash: t.InfoHash().HexString(), Status: downloadActive}
+	m.mu.Lock()
+	m.status[r.InfoHash] = r
+	m.mu.Unlock()
+
+	sub := t.SubscribePieceStateChanges()
+	defer sub.Close()
+	checking := make(map[int]bool)
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-t.Closed():
+			m.finish(r, downloadFailed)
+			return
+		case v, ok := <-sub.Values:
+			if !ok {
+				continue
+			}
+			change := v.(torrent.PieceStateChange)
+			if change.Checking {
+				checking[change.Index] = true
+				continue
+			}
+			if checking[change.Index] && !change.Complete {
+				m.hashFailed(r)
+			}
+			delete(checking, change.Index)
+			if r.HashFailures >= m.maxHashFailures {
+				t.Drop()
+				m.finish(r, downloadFailed)
+				return
+			}
+		case <-tick.C:
+			m.forgive()
+			if t.BytesCompleted() < t.Info().TotalLength() {
+				continue
+			}
+			// re-verify everything before the data is handed on
+			t.VerifyData()
+			if t.BytesCompleted() == t.Info().TotalLength() {
+				m.finish(r, downloadCompleted)
+				return
+			}
+		}
+	}
+}
+
+// printStatus lists every download and its state
+func (m *downloadManager) printStatus() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.status {
+		fmt.Printf("%s  %-30s %-12s %d hash failures\n", r.InfoHash, r.Name, r.Status, r.HashFailures)
+	}
+}
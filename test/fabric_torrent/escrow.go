@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/keywrap"
+	"github.com/pkg/errors"
+)
+
+// escrowRecipient is the recovery identity of the organisation the key of every
+// published file is escrowed for through the keyExchange chaincode, nil disables escrow
+var (
+	escrowRecipient *ecdsa.PublicKey
+	escrowName      string
+)
+
+// escrowKey stores key on the ledger wrapped for escrowRecipient
+func escrowKey(chClient chclient.ChannelClient, file catalog.FileKey, key []byte) error {
+	wrapped, err := keywrap.Wrap(escrowRecipient, key)
+	if err != nil {
+		return err
+	}
+	if err := fabricclient.NewExchange(chClient).Escrow(context.Background(), file, escrowName, hex.EncodeToString(wrapped)); err != nil {
+		return errors.Wrap(err, "Failed to escrow key")
+	}
+	return nil
+}
+
+// escrowHexKey escrows the hex encoded key returned by encryptEntry, if escrow is enabled
+func escrowHexKey(chClient chclient.ChannelClient, file catalog.FileKey, hexKey string) {
+	if escrowRecipient == nil {
+		return
+	}
+	key, err := hex.DecodeString(hexKey)
+	if err == nil {
+		err = escrowKey(chClient, file, key)
+	}
+	if err != nil {
+		fmt.Println("unable to escrow key of", file.Name, err)
+	}
+}
+
+// recoverEscrow imports into the key store the keys escrowed for the calling identity
+// of every file published by owner, and returns how many were imported
+func recoverEscrow(chClient chclient.ChannelClient, owner string, priv *ecdsa.PrivateKey) (int, error) {
+	files, err := ledgerFiles(chClient, owner)
+	if err != nil {
+		return 0, err
+	}
+	exchange := fabricclient.NewExchange(chClient)
+	recovered := 0
+	for _, f := range files {
+		escrowed, err := exchange.Escrowed(context.Background(), f.Key())
+		if err != nil {
+			fmt.Println("no escrowed key for", f.Name, err)
+			continue
+		}
+		wrapped, err := hex.DecodeString(escrowed)
+		if err != nil {
+			return recovered, errors.Wrapf(err, "invalid escrow of %s", f.Name)
+		}
+		key, err := keywrap.Unwrap(priv, wrapped)
+		if err != nil {
+			return recovered, errors.Wrapf(err, "unable to unwrap escrow of %s", f.Name)
+		}
+		r := keyRecord{ID: f.Hash, Name: f.Name, Algorithm: f.Cipher, Version: int(f.Version), Created: time.Now(), Key: key}
+		if m, err := metainfo.ParseMagnetURI(f.Magnet); err == nil {
+			r.InfoHash = m.InfoHash.HexString()
+		}
+		ok, err := importRecord(r)
+		if err != nil {
+			return recovered, err
+		}
+		if ok {
+			recovered++
+		}
+	}
+	return recovered, nil
+}
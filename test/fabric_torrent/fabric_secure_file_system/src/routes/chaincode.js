@@ -98,7 +98,7 @@ var Chaincode = {
 };
 
 function decryfile(key,filename) {
-    var command="go run main.go "+key+" ../encryptdata/"+filename+" ../decryptdata/"+filename;
+    var command="../fabric_torrent decrypt "+key+" ../encryptdata/"+filename+" ../decryptdata/"+filename;
     console.log(command)
     exec(command, (err, stdout, stderr) => {
         if (err) {
@@ -0,0 +1,119 @@
+package fabricclient
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+	"github.com/pkg/errors"
+)
+
+// Catalog calls the catalog chaincode, the registry of the published files
+type Catalog struct {
+	invoker
+}
+
+// NewCatalog returns the catalog seen through chClient
+func NewCatalog(chClient chclient.ChannelClient) *Catalog {
+	return &Catalog{invoker{chClient: chClient, chaincode: CatalogCC}}
+}
+
+// FileSpec is the content of a file CreateFile registers. UpdateFile replaces Hash,
+// Magnet, Size, Info and Cipher. The key of the file is not part of it, it is handed out
+// through the Exchange only.
+type FileSpec struct {
+	Name    string
+	Hash    string
+	Keyword string
+	Summary string
+	Magnet  string
+	Size    int64
+	Info    []byte
+	Cipher  string
+	Access  string
+}
+
+// CreateFile registers a file owned by the caller and returns its key
+func (c *Catalog) CreateFile(ctx context.Context, spec FileSpec) (catalog.FileKey, error) {
+	response, err := c.execute(ctx, "createFile", spec.Name, spec.Hash, spec.Keyword, spec.Summary,
+		spec.Magnet, "", strconv.FormatInt(spec.Size, 10), string(spec.Info), spec.Cipher, spec.Access)
+	if err != nil {
+		return catalog.FileKey{}, err
+	}
+	return catalog.FileKey{Keyword: spec.Keyword, Name: spec.Name, Owner: string(response.Payload)}, nil
+}
+
+// UpdateFile records new content for the file of key, which increments its version
+func (c *Catalog) UpdateFile(ctx context.Context, key catalog.FileKey, spec FileSpec) error {
+	_, err := c.execute(ctx, "updateFile", key.Keyword, key.Name, key.Owner, spec.Hash, spec.Magnet, "",
+		strconv.FormatInt(spec.Size, 10), string(spec.Info), spec.Cipher)
+	return err
+}
+
+// UpdateMeta replaces summary and access policy of the file of key
+func (c *Catalog) UpdateMeta(ctx context.Context, key catalog.FileKey, summary, access string) error {
+	_, err := c.execute(ctx, "updateFileMeta", key.Keyword, key.Name, key.Owner, summary, access)
+	return err
+}
+
+// DeleteFile removes the file of key from the catalog
+func (c *Catalog) DeleteFile(ctx context.Context, key catalog.FileKey) error {
+	_, err := c.execute(ctx, "deleteFile", key.Keyword, key.Name, key.Owner)
+	return err
+}
+
+// Filter selects files by the attributes of their key, empty ones match every file
+type Filter struct {
+	Keyword string
+	Name    string
+	Owner   string
+}
+
+func (f Filter) match(file catalog.File) bool {
+	return (f.Keyword == "" || f.Keyword == file.Keyword) &&
+		(f.Name == "" || f.Name == file.Name) &&
+		(f.Owner == "" || f.Owner == file.Owner)
+}
+
+// Query returns the files filter selects
+func (c *Catalog) Query(ctx context.Context, filter Filter) ([]catalog.File, error) {
+	// the ledger looks up leading attributes of the key, the others are matched here
+	var prefix []string
+	for _, attribute := range []string{filter.Keyword, filter.Name, filter.Owner} {
+		if attribute == "" {
+			break
+		}
+		prefix = append(prefix, attribute)
+	}
+	payload, err := c.query(ctx, "queryFileRecords", prefix...)
+	if err != nil {
+		return nil, err
+	}
+	records, err := catalog.DecodeRecords(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode file records")
+	}
+	files := records[:0]
+	for _, file := range records {
+		if filter.match(file) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// Get returns the file of key, an error with Reason ErrNotFound when there is none. An
+// empty keyword of key matches the file of any keyword.
+func (c *Catalog) Get(ctx context.Context, key catalog.FileKey) (catalog.File, error) {
+	files, err := c.Query(ctx, Filter{Keyword: key.Keyword, Name: key.Name, Owner: key.Owner})
+	if err != nil {
+		return catalog.File{}, err
+	}
+	for _, file := range files {
+		if file.Name == key.Name && file.Owner == key.Owner && (key.Keyword == "" || file.Keyword == key.Keyword) {
+			return file, nil
+		}
+	}
+	return catalog.File{}, &CallError{Chaincode: CatalogCC, Fcn: "queryFileRecords", Reason: ErrNotFound, Err: errors.Errorf("no file %s of %s", key.Name, key.Owner)}
+}
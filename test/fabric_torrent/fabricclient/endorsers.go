@@ -0,0 +1,83 @@
+package fabricclient
+
+import (
+	"github.com/hyperledger/fabric-sdk-go/api/apiconfig"
+	fab "github.com/hyperledger/fabric-sdk-go/api/apifabclient"
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabric-client/peer"
+	"github.com/pkg/errors"
+)
+
+// Peers returns the peers of org the SDK configuration lists
+func (c *Client) Peers(org string) ([]fab.Peer, error) {
+	if peers, ok := c.peers[org]; ok {
+		return peers, nil
+	}
+	mspID := org + "MSP"
+	if c.Manifest != nil {
+		mspID = c.Manifest.mspID(org)
+	}
+	peersConfig, err := c.SDK.Config().PeersConfig(org)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no peers configured for %s", org)
+	}
+	var peers []fab.Peer
+	for _, peerConfig := range peersConfig {
+		target, err := peer.New(c.SDK.Config(), peer.FromPeerConfig(&apiconfig.NetworkPeer{PeerConfig: peerConfig, MspID: mspID}))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid peer %s of %s", peerConfig.URL, org)
+		}
+		peers = append(peers, target)
+	}
+	if len(peers) == 0 {
+		return nil, errors.Errorf("no peers configured for %s", org)
+	}
+	if c.peers == nil {
+		c.peers = make(map[string][]fab.Peer)
+	}
+	c.peers[org] = peers
+	return peers, nil
+}
+
+// Endorsers returns peers whose endorsements satisfy the policy of chaincode in the
+// manifest and those of the chaincodes it invokes, nil when the manifest has no policy
+// for it and the SDK chooses.
+func (c *Client) Endorsers(chaincode string) ([]fab.Peer, error) {
+	if c.Manifest == nil {
+		return nil, nil
+	}
+	var endorsers []fab.Peer
+	for _, name := range append([]string{chaincode}, invokes[chaincode]...) {
+		cc := c.Manifest.Chaincode(name)
+		if cc == nil {
+			continue
+		}
+		peers := make(map[string][]fab.Peer)
+		for _, org := range c.Manifest.Channel(cc.Channel).Orgs {
+			orgPeers, err := c.Peers(org)
+			if err != nil {
+				return nil, err
+			}
+			peers[org] = orgPeers
+		}
+		var err error
+		if endorsers, err = cc.Policy.endorsers(peers, endorsers); err != nil {
+			return nil, errors.Wrapf(err, "unable to satisfy the endorsement policy of %s", name)
+		}
+	}
+	return endorsers, nil
+}
+
+// endorsingClient sends the transactions of a chaincode to the peers of endorsers, unless
+// the call names its own
+type endorsingClient struct {
+	chclient.ChannelClient
+	endorsers map[string][]fab.ProposalProcessor
+}
+
+func (e *endorsingClient) Execute(request chclient.Request, options ...chclient.Option) (chclient.Response, error) {
+	if endorsers, ok := e.endorsers[request.ChaincodeID]; ok {
+		options = append([]chclient.Option{chclient.WithProposalProcessor(endorsers...)}, options...)
+	}
+	return e.ChannelClient.Execute(request, options...)
+}
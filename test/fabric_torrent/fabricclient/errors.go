@@ -0,0 +1,82 @@
+package fabricclient
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Reasons the chaincodes refuse a call for, returned by Reason
+var (
+	ErrNotFound = errors.New("not found")
+	ErrExists   = errors.New("already exists")
+	ErrLocked   = errors.New("file locked by a pending exchange")
+	ErrDenied   = errors.New("permission denied")
+	ErrRevoked  = errors.New("request revoked")
+	ErrAnswered = errors.New("request already answered")
+	ErrNoShare  = errors.New("no share held")
+)
+
+// reasons maps the messages of shim.Error in the chaincodes to the reason they stand for
+var reasons = []struct {
+	message string
+	reason  error
+}{
+	{"already exist a file", ErrExists},
+	{"is not exist", ErrNotFound},
+	{"no secret escrowed", ErrNotFound},
+	{"is locked", ErrLocked},
+	{"permission denied", ErrDenied},
+	{"wrong transaction id", ErrDenied},
+	{"has been revoked", ErrRevoked},
+	{"already has a response", ErrAnswered},
+	{"has been confirmed", ErrAnswered},
+	{"already been delivered", ErrAnswered},
+	{"holds no share", ErrNoShare},
+}
+
+// CallError is a chaincode call that failed
+type CallError struct {
+	Chaincode string
+	Fcn       string
+	// Reason is one of the Err values when the chaincode refused the call for it
+	Reason error
+	Err    error
+}
+
+func (e *CallError) Error() string {
+	return e.Chaincode + " " + e.Fcn + ": " + e.Err.Error()
+}
+
+// Cause returns the error of the SDK, for errors.Cause
+func (e *CallError) Cause() error {
+	return e.Err
+}
+
+// Reason returns why the chaincode refused the call that returned err, even wrapped,
+// nil when err is not a refusal the chaincodes tell apart
+func Reason(err error) error {
+	for err != nil {
+		if e, ok := err.(*CallError); ok {
+			return e.Reason
+		}
+		cause, ok := err.(interface {
+			Cause() error
+		})
+		if !ok {
+			return nil
+		}
+		err = cause.Cause()
+	}
+	return nil
+}
+
+func callError(chaincode, fcn string, err error) error {
+	message := strings.ToLower(err.Error())
+	for _, r := range reasons {
+		if strings.Contains(message, r.message) {
+			return &CallError{Chaincode: chaincode, Fcn: fcn, Reason: r.reason, Err: err}
+		}
+	}
+	return &CallError{Chaincode: chaincode, Fcn: fcn, Err: err}
+}
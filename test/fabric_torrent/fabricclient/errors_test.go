@@ -0,0 +1,51 @@
+package fabricclient
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestReason(t *testing.T) {
+	tests := []struct {
+		message string
+		reason  error
+	}{
+		{"Already exist a file having the same name", ErrExists},
+		{"The file is not exist", ErrNotFound},
+		{"no secret escrowed for the file", ErrNotFound},
+		{"The file is locked for request", ErrLocked},
+		{"Permission denied by the access policy of the file", ErrDenied},
+		{"wrong transaction id", ErrDenied},
+		{"This request has been revoked", ErrRevoked},
+		{"the request already has a response", ErrAnswered},
+		{"the request has been confirmed", ErrAnswered},
+		{"the key has already been delivered", ErrAnswered},
+		{"User1@org2.example.com holds no share of the file", ErrNoShare},
+		{"Incorrect number of arguments", nil},
+		{"connection refused", nil},
+	}
+	for _, test := range tests {
+		err := callError(CatalogCC, "createFile", errors.New("chaincode error: "+test.message))
+		if reason := Reason(err); reason != test.reason {
+			t.Errorf("%q: reason %v, expected %v", test.message, reason, test.reason)
+		}
+		if reason := Reason(errors.Wrap(err, "Failed to add a magnetlink")); reason != test.reason {
+			t.Errorf("%q wrapped: reason %v, expected %v", test.message, reason, test.reason)
+		}
+	}
+
+	cause := errors.New("The file is not exist")
+	err := callError(CatalogCC, "queryFile", cause)
+	if errors.Cause(err) != cause {
+		t.Error("the cause of a call error is not the error of the SDK")
+	}
+	if err.Error() != CatalogCC+" queryFile: "+cause.Error() {
+		t.Errorf("message %q", err)
+	}
+	for _, err := range []error{nil, cause, errors.Wrap(cause, "wrapped")} {
+		if reason := Reason(err); reason != nil {
+			t.Errorf("%v: reason %v of an error that is no call error", err, reason)
+		}
+	}
+}
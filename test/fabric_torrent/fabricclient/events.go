@@ -0,0 +1,16 @@
+package fabricclient
+
+import (
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/pkg/errors"
+)
+
+// Events registers for the events of chaincode ccID whose name matches the regular
+// expression filter. They are delivered on the returned channel from then on.
+func Events(chClient chclient.ChannelClient, ccID, filter string) (<-chan *chclient.CCEvent, error) {
+	notifier := make(chan *chclient.CCEvent)
+	if _, err := chClient.RegisterChaincodeEvent(notifier, ccID, filter); err != nil {
+		return nil, errors.Wrapf(err, "Failed to register for %s events of %s", filter, ccID)
+	}
+	return notifier, nil
+}
@@ -0,0 +1,142 @@
+package fabricclient
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+	"github.com/pkg/errors"
+)
+
+// Exchange calls the key exchange chaincode, through which owners and share holders
+// hand file keys to the users allowed to request them
+type Exchange struct {
+	invoker
+}
+
+// NewExchange returns the key exchange seen through chClient
+func NewExchange(chClient chclient.ChannelClient) *Exchange {
+	return &Exchange{invoker{chClient: chClient, chaincode: ExchangeCC}}
+}
+
+// Request asks the owner of the file of key for its key, wrapped for pubKey, and returns
+// the id of the request the answers refer to
+func (e *Exchange) Request(ctx context.Context, key catalog.FileKey, pubKey string) (string, error) {
+	response, err := e.execute(ctx, "requestSecret", key.Keyword, key.Name, key.Owner, pubKey)
+	if err != nil {
+		return "", err
+	}
+	return response.TransactionID.ID, nil
+}
+
+// Respond answers requests for the same file with its key wrapped as secret
+func (e *Exchange) Respond(ctx context.Context, secret string, txIDs ...string) error {
+	if len(txIDs) == 0 {
+		return errors.New("no request to respond to")
+	}
+	args := append(append([]string{}, txIDs...), secret)
+	_, err := e.execute(ctx, "respondSecret", args...)
+	return err
+}
+
+// Confirm records that the answer to request txID was received
+func (e *Exchange) Confirm(ctx context.Context, txID string) error {
+	_, err := e.execute(ctx, "confirmSecret", txID)
+	return err
+}
+
+// Revoke revokes the unanswered requests for the file of key and returns their ids
+func (e *Exchange) Revoke(ctx context.Context, key catalog.FileKey) ([]string, error) {
+	response, err := e.execute(ctx, "revokeRequests", key.Keyword, key.Name, key.Owner)
+	if err != nil {
+		return nil, err
+	}
+	var message catalog.RevocationMessage
+	if err := json.Unmarshal(response.Payload, &message); err != nil {
+		return nil, errors.Wrap(err, "unable to decode revocation")
+	}
+	return message.TxID, nil
+}
+
+// QueryRequest returns the record of request txID
+func (e *Exchange) QueryRequest(ctx context.Context, txID string) (catalog.Request, error) {
+	payload, err := e.query(ctx, "queryRequest", txID)
+	if err != nil {
+		return catalog.Request{}, err
+	}
+	var record struct {
+		Key    string
+		Record catalog.Request
+	}
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return catalog.Request{}, errors.Wrap(err, "unable to decode request")
+	}
+	return record.Record, nil
+}
+
+// Share is the share of a file key held by Holder, wrapped for it
+type Share struct {
+	Holder string
+	Secret string
+}
+
+// DepositShares splits answering the requests for the file of key among the holders of
+// shares, any threshold of which recover the key
+func (e *Exchange) DepositShares(ctx context.Context, key catalog.FileKey, threshold int, shares []Share) (catalog.ShareConfig, error) {
+	args := []string{key.Keyword, key.Name, key.Owner, strconv.Itoa(threshold)}
+	for _, share := range shares {
+		args = append(args, share.Holder, share.Secret)
+	}
+	response, err := e.execute(ctx, "depositShares", args...)
+	if err != nil {
+		return catalog.ShareConfig{}, err
+	}
+	var config catalog.ShareConfig
+	if err := json.Unmarshal(response.Payload, &config); err != nil {
+		return catalog.ShareConfig{}, errors.Wrap(err, "unable to decode share configuration")
+	}
+	return config, nil
+}
+
+// ShareConfig returns how the key of the file of key is split, nil when only its owner
+// has it
+func (e *Exchange) ShareConfig(ctx context.Context, key catalog.FileKey) (*catalog.ShareConfig, error) {
+	payload, err := e.query(ctx, "queryShares", key.Keyword, key.Name, key.Owner)
+	if err != nil || len(payload) == 0 {
+		return nil, err
+	}
+	var config catalog.ShareConfig
+	if err := json.Unmarshal(payload, &config); err != nil {
+		return nil, errors.Wrap(err, "unable to decode share configuration")
+	}
+	return &config, nil
+}
+
+// Share returns the share of the key of the file of key the caller holds, wrapped for it.
+// The error has Reason ErrNoShare when it holds none.
+func (e *Exchange) Share(ctx context.Context, key catalog.FileKey) (string, error) {
+	payload, err := e.query(ctx, "queryShare", key.Keyword, key.Name, key.Owner)
+	return string(payload), err
+}
+
+// RespondShare answers request txID with the share of the caller, wrapped for the requester
+func (e *Exchange) RespondShare(ctx context.Context, txID, share string) error {
+	_, err := e.execute(ctx, "respondShare", txID, share)
+	return err
+}
+
+// Escrow stores the key of the file of key wrapped as secret for recipient, which
+// recovers it with Escrowed
+func (e *Exchange) Escrow(ctx context.Context, key catalog.FileKey, recipient, secret string) error {
+	_, err := e.execute(ctx, "escrowSecret", key.Keyword, key.Name, key.Owner, recipient, secret)
+	return err
+}
+
+// Escrowed returns the key of the file of key escrowed for the caller, still wrapped.
+// The error has Reason ErrNotFound when there is none.
+func (e *Exchange) Escrowed(ctx context.Context, key catalog.FileKey) (string, error) {
+	payload, err := e.query(ctx, "queryEscrow", key.Keyword, key.Name, key.Owner)
+	return string(payload), err
+}
@@ -0,0 +1,141 @@
+// Package fabricclient sets up the SDK for the identity a fabric_torrent command acts as.
+package fabricclient
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+
+	fab "github.com/hyperledger/fabric-sdk-go/api/apifabclient"
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/pkg/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/pkg/errors"
+)
+
+// Names the chaincodes are instantiated under. keyExchange invokes the catalog by its
+// name, so they cannot be chosen per command.
+const (
+	CatalogCC  = "myapp"
+	ExchangeCC = "keyExchange"
+	DHTCC      = "dht_server"
+)
+
+// invokes lists the chaincodes a chaincode writes to through InvokeChaincode, their
+// policies apply to its transactions too
+var invokes = map[string][]string{
+	ExchangeCC: {CatalogCC},
+}
+
+// Config is the network, channel and identity a command works with
+type Config struct {
+	ConfigFile string
+	Channel    string
+	Org        string
+	User       string
+	// Manifest is the path of the manifest of the network, none when empty
+	Manifest string
+}
+
+// Flags registers the flags of Config, their defaults are those of the test network
+func Flags() *Config {
+	c := &Config{}
+	flag.StringVar(&c.ConfigFile, "config", "config_test.yaml", "SDK configuration of the network")
+	flag.StringVar(&c.Channel, "channel", "orgchannel", "channel the chaincodes are instantiated on")
+	flag.StringVar(&c.Org, "org", "Org1", "organisation of the user")
+	flag.StringVar(&c.User, "user", "User1", "user to act as")
+	flag.StringVar(&c.Manifest, "manifest", "network.yaml", "manifest of the network, the endorsement policies of the chaincodes")
+	return c
+}
+
+// Client is the SDK set up from a Config
+type Client struct {
+	Config
+	SDK *fabsdk.FabricSDK
+	// Manifest is loaded from Config.Manifest, nil without one
+	Manifest *Manifest
+	// peers by org, see Peers
+	peers map[string][]fab.Peer
+}
+
+// New creates the SDK described by c
+func New(c Config) (*Client, error) {
+	sdk, err := fabsdk.New(config.FromFile(c.ConfigFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create new SDK")
+	}
+	client := &Client{Config: c, SDK: sdk}
+	if c.Manifest != "" {
+		if client.Manifest, err = LoadManifest(c.Manifest); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// ChannelClient returns the channel client of the user. It sends the transactions of
+// the chaincodes of the manifest to peers satisfying their endorsement policies.
+func (c *Client) ChannelClient() (chclient.ChannelClient, error) {
+	chClient, err := c.SDK.NewClient(fabsdk.WithUser(c.User), fabsdk.WithOrg(c.Org)).Channel(c.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create new channel client for %s of %s", c.User, c.Org)
+	}
+	if c.Manifest == nil {
+		return chClient, nil
+	}
+	endorsing := &endorsingClient{ChannelClient: chClient, endorsers: make(map[string][]fab.ProposalProcessor)}
+	for _, cc := range c.Manifest.Chaincodes {
+		if cc.Channel != c.Channel {
+			continue
+		}
+		peers, err := c.Endorsers(cc.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, peer := range peers {
+			endorsing.endorsers[cc.Name] = append(endorsing.endorsers[cc.Name], peer)
+		}
+	}
+	return endorsing, nil
+}
+
+// Identity returns the identity of user of org
+func (c *Client) Identity(org, user string) (fab.IdentityContext, error) {
+	session, err := c.SDK.NewClient(fabsdk.WithUser(user), fabsdk.WithOrg(org)).Session()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Session failed, %s, %s", org, user)
+	}
+	return session, nil
+}
+
+// Owner returns the name the chaincodes record as owner of what the user publishes
+func (c *Client) Owner() (string, error) {
+	identity, err := c.Identity(c.Org, c.User)
+	if err != nil {
+		return "", err
+	}
+	return CommonName(identity)
+}
+
+// CommonName returns the common name of the certificate of identity, which the chaincodes
+// record as the owner of what it publishes
+func CommonName(identity fab.IdentityContext) (string, error) {
+	serialized, err := identity.Identity()
+	if err != nil {
+		return "", err
+	}
+	certStart := bytes.Index(serialized, []byte("-----BEGIN"))
+	if certStart == -1 {
+		return "", errors.New("no certificate detected")
+	}
+	content, _ := pem.Decode(serialized[certStart:])
+	if content == nil {
+		return "", errors.New("fail to decode the certificate")
+	}
+	cert, err := x509.ParseCertificate(content.Bytes)
+	if err != nil {
+		return "", errors.Wrap(err, "fail when parsing the x509 certificate")
+	}
+	return cert.Subject.CommonName, nil
+}
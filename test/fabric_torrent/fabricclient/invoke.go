@@ -0,0 +1,60 @@
+package fabricclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+)
+
+// invoker calls the functions of a chaincode with string arguments
+type invoker struct {
+	chClient  chclient.ChannelClient
+	chaincode string
+}
+
+func (i invoker) request(fcn string, args []string) chclient.Request {
+	request := chclient.Request{ChaincodeID: i.chaincode, Fcn: fcn, Args: make([][]byte, len(args))}
+	for n, arg := range args {
+		request.Args[n] = []byte(arg)
+	}
+	return request
+}
+
+// options bounds the call by the deadline of ctx
+func options(ctx context.Context) ([]chclient.Option, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var opts []chclient.Option
+	if deadline, ok := ctx.Deadline(); ok {
+		opts = append(opts, chclient.WithTimeout(time.Until(deadline)))
+	}
+	return opts, nil
+}
+
+// query evaluates fcn without committing a transaction and returns its payload
+func (i invoker) query(ctx context.Context, fcn string, args ...string) ([]byte, error) {
+	opts, err := options(ctx)
+	if err != nil {
+		return nil, err
+	}
+	response, err := i.chClient.Query(i.request(fcn, args), opts...)
+	if err != nil {
+		return nil, callError(i.chaincode, fcn, err)
+	}
+	return response.Payload, nil
+}
+
+// execute commits a transaction calling fcn
+func (i invoker) execute(ctx context.Context, fcn string, args ...string) (chclient.Response, error) {
+	opts, err := options(ctx)
+	if err != nil {
+		return chclient.Response{}, err
+	}
+	response, err := i.chClient.Execute(i.request(fcn, args), opts...)
+	if err != nil {
+		return chclient.Response{}, callError(i.chaincode, fcn, err)
+	}
+	return response, nil
+}
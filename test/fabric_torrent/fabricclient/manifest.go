@@ -0,0 +1,185 @@
+package fabricclient
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Manifest describes the channels, orgs and chaincodes of the network, network.yaml
+// describes its format. bootstrap sets it up, clients choose the peers endorsing their
+// transactions by the policies of its chaincodes.
+type Manifest struct {
+	Admin      string              `yaml:"admin"`
+	OrdererOrg string              `yaml:"ordererOrg"`
+	Gopath     string              `yaml:"gopath"`
+	Orgs       []OrgManifest       `yaml:"orgs"`
+	Channels   []ChannelManifest   `yaml:"channels"`
+	Chaincodes []ChaincodeManifest `yaml:"chaincodes"`
+}
+
+// OrgManifest is an org of the network
+type OrgManifest struct {
+	Name  string `yaml:"name"`
+	MSPID string `yaml:"mspID"`
+}
+
+// ChannelManifest is a channel the peers of Orgs join
+type ChannelManifest struct {
+	Name string   `yaml:"name"`
+	Tx   string   `yaml:"tx"`
+	Orgs []string `yaml:"orgs"`
+}
+
+// ChaincodeManifest is a chaincode instantiated on Channel
+type ChaincodeManifest struct {
+	Name    string   `yaml:"name"`
+	Path    string   `yaml:"path"`
+	Version string   `yaml:"version"`
+	Channel string   `yaml:"channel"`
+	Args    []string `yaml:"args"`
+	// Policy is the endorsement policy, a member of any org of the channel when empty
+	Policy *Policy `yaml:"policy"`
+}
+
+// LoadManifest reads the manifest at path, fills in the defaults and checks that what
+// it refers to is declared and that the policies are valid
+func LoadManifest(path string) (*Manifest, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := yaml.UnmarshalStrict(content, m); err != nil {
+		return nil, errors.Wrapf(err, "invalid manifest %s", path)
+	}
+	if err := m.resolve(filepath.Dir(path)); err != nil {
+		return nil, errors.Wrapf(err, "invalid manifest %s", path)
+	}
+	return m, nil
+}
+
+func (m *Manifest) resolve(dir string) error {
+	if m.Admin == "" {
+		m.Admin = "Admin"
+	}
+	if m.OrdererOrg == "" {
+		return errors.New("no ordererOrg")
+	}
+	if m.Gopath == "" && len(m.Chaincodes) > 0 {
+		return errors.New("no gopath for the chaincodes")
+	}
+	m.Gopath = resolvePath(dir, m.Gopath)
+
+	for i := range m.Orgs {
+		org := &m.Orgs[i]
+		if org.Name == "" {
+			return errors.Errorf("org %d has no name", i)
+		}
+		if m.Org(org.Name) != org {
+			return errors.Errorf("org %s is declared twice", org.Name)
+		}
+		if org.MSPID == "" {
+			org.MSPID = org.Name + "MSP"
+		}
+	}
+
+	for i := range m.Channels {
+		channel := &m.Channels[i]
+		if channel.Name == "" {
+			return errors.Errorf("channel %d has no name", i)
+		}
+		if m.Channel(channel.Name) != channel {
+			return errors.Errorf("channel %s is declared twice", channel.Name)
+		}
+		if len(channel.Orgs) == 0 {
+			return errors.Errorf("channel %s has no org", channel.Name)
+		}
+		for _, org := range channel.Orgs {
+			if m.Org(org) == nil {
+				return errors.Errorf("channel %s: undeclared org %s", channel.Name, org)
+			}
+		}
+		if channel.Tx == "" {
+			channel.Tx = filepath.Join("v1.1/channel", channel.Name+".tx")
+		}
+		channel.Tx = resolvePath(dir, channel.Tx)
+	}
+
+	for i := range m.Chaincodes {
+		cc := &m.Chaincodes[i]
+		if cc.Name == "" || cc.Path == "" || cc.Version == "" {
+			return errors.Errorf("chaincode %d needs a name, a path and a version", i)
+		}
+		if m.Chaincode(cc.Name) != cc {
+			return errors.Errorf("chaincode %s is declared twice", cc.Name)
+		}
+		channel := m.Channel(cc.Channel)
+		if channel == nil {
+			return errors.Errorf("chaincode %s: undeclared channel %q", cc.Name, cc.Channel)
+		}
+		if cc.Policy == nil {
+			cc.Policy = anyMember(channel.Orgs)
+		}
+		if err := cc.Policy.validate(channel.Orgs); err != nil {
+			return errors.Wrapf(err, "chaincode %s: invalid policy", cc.Name)
+		}
+	}
+	return nil
+}
+
+// Org returns the org name, nil if it is not declared
+func (m *Manifest) Org(name string) *OrgManifest {
+	for i := range m.Orgs {
+		if m.Orgs[i].Name == name {
+			return &m.Orgs[i]
+		}
+	}
+	return nil
+}
+
+// Channel returns the channel name, nil if it is not declared
+func (m *Manifest) Channel(name string) *ChannelManifest {
+	for i := range m.Channels {
+		if m.Channels[i].Name == name {
+			return &m.Channels[i]
+		}
+	}
+	return nil
+}
+
+// Chaincode returns the chaincode name, nil if it is not declared
+func (m *Manifest) Chaincode(name string) *ChaincodeManifest {
+	for i := range m.Chaincodes {
+		if m.Chaincodes[i].Name == name {
+			return &m.Chaincodes[i]
+		}
+	}
+	return nil
+}
+
+// mspID returns the MSP of org, by the usual naming when it is not declared
+func (m *Manifest) mspID(org string) string {
+	if declared := m.Org(org); declared != nil {
+		return declared.MSPID
+	}
+	return org + "MSP"
+}
+
+// InitArgs returns the init arguments of cc as the SDK takes them
+func (cc *ChaincodeManifest) InitArgs() [][]byte {
+	args := make([][]byte, len(cc.Args))
+	for i, arg := range cc.Args {
+		args[i] = []byte(arg)
+	}
+	return args
+}
+
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,135 @@
+package fabricclient
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeManifest(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, "network.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m, err := LoadManifest(writeManifest(t, dir, `
+ordererOrg: ordererorg
+gopath: ../testdata
+orgs:
+  - name: Org1
+  - name: Org2
+    mspID: Org2Members
+channels:
+  - name: relative
+    orgs: [Org1, Org2]
+  - name: absolute
+    tx: /channel/absolute.tx
+    orgs: [Org2]
+chaincodes:
+  - name: open
+    path: github.com/open
+    version: "0"
+    channel: relative
+  - name: strict
+    path: github.com/strict
+    version: "1"
+    channel: relative
+    policy:
+      require: 2
+      of:
+        - org: Org1
+        - org: Org2
+          role: peer
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"admin", m.Admin, "Admin"},
+		{"gopath", m.Gopath, filepath.Join(filepath.Dir(dir), "testdata")},
+		{"default msp", m.mspID("Org1"), "Org1MSP"},
+		{"declared msp", m.mspID("Org2"), "Org2Members"},
+		{"undeclared msp", m.mspID("Org3"), "Org3MSP"},
+		{"default tx", m.Channel("relative").Tx, filepath.Join(dir, "v1.1/channel/relative.tx")},
+		{"absolute tx", m.Channel("absolute").Tx, "/channel/absolute.tx"},
+	}
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("%s: %q, expected %q", test.name, test.got, test.expected)
+		}
+	}
+
+	open := m.Chaincode("open").Policy
+	if open.Require != 1 || len(open.Of) != 2 || open.Of[0] != (Principal{Org: "Org1", Role: "member"}) || open.Of[1] != (Principal{Org: "Org2", Role: "member"}) {
+		t.Errorf("default policy %+v, expected a member of Org1 or Org2", open)
+	}
+	strict := m.Chaincode("strict").Policy
+	if strict.Require != 2 || strict.Of[0].Role != "member" || strict.Of[1].Role != "peer" {
+		t.Errorf("policy %+v", strict)
+	}
+	if m.Chaincode("missing") != nil || m.Channel("missing") != nil || m.Org("missing") != nil {
+		t.Error("found an undeclared entry")
+	}
+}
+
+func TestLoadManifestInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	const network = `
+ordererOrg: ordererorg
+gopath: testdata
+orgs: [{name: Org1}, {name: Org2}]
+channels: [{name: ch, orgs: [Org1]}]
+`
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{"unknown field", network + "peers: []\n"},
+		{"no ordererOrg", "gopath: testdata\n"},
+		{"no gopath", "ordererOrg: o\norgs: [{name: Org1}]\nchannels: [{name: ch, orgs: [Org1]}]\nchaincodes: [{name: cc, path: p, version: '0', channel: ch}]\n"},
+		{"org declared twice", "ordererOrg: o\norgs: [{name: Org1}, {name: Org1}]\n"},
+		{"org without name", "ordererOrg: o\norgs: [{mspID: Org1MSP}]\n"},
+		{"channel declared twice", "ordererOrg: o\norgs: [{name: Org1}]\nchannels: [{name: ch, orgs: [Org1]}, {name: ch, orgs: [Org1]}]\n"},
+		{"channel without orgs", "ordererOrg: o\nchannels: [{name: ch}]\n"},
+		{"channel of an undeclared org", "ordererOrg: o\nchannels: [{name: ch, orgs: [Org3]}]\n"},
+		{"chaincode without version", network + "chaincodes: [{name: cc, path: p, channel: ch}]\n"},
+		{"chaincode declared twice", network + "chaincodes: [{name: cc, path: p, version: '0', channel: ch}, {name: cc, path: p, version: '0', channel: ch}]\n"},
+		{"chaincode of an undeclared channel", network + "chaincodes: [{name: cc, path: p, version: '0', channel: other}]\n"},
+		{"policy of an org out of the channel", network + "chaincodes: [{name: cc, path: p, version: '0', channel: ch, policy: {of: [{org: Org2}]}}]\n"},
+	}
+	for _, test := range tests {
+		if _, err := LoadManifest(writeManifest(t, dir, test.manifest)); err == nil {
+			t.Errorf("%s: loaded", test.name)
+		}
+	}
+}
+
+func TestLoadNetworkManifest(t *testing.T) {
+	m, err := LoadManifest("../network.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{DHTCC, CatalogCC, ExchangeCC} {
+		if m.Chaincode(name) == nil {
+			t.Errorf("%s is not declared", name)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package fabricclient
+
+import (
+	"github.com/golang/protobuf/proto"
+	fab "github.com/hyperledger/fabric-sdk-go/api/apifabclient"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/common/cauthdsl"
+	cb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/msp"
+	"github.com/pkg/errors"
+)
+
+// endorsingRoles are the roles a Principal may require. Endorsements are signed by
+// peers, which are members of their org and, when the MSP classifies its identities
+// (NodeOUs), peers. Admins and clients never endorse.
+var endorsingRoles = map[string]msp.MSPRole_MSPRoleType{
+	"member": msp.MSPRole_MEMBER,
+	"peer":   msp.MSPRole_PEER,
+}
+
+// Principal is an endorser a Policy counts: a peer of Org with Role, member by default
+type Principal struct {
+	Org  string `yaml:"org"`
+	Role string `yaml:"role"`
+}
+
+// Policy is an endorsement policy: a transaction is valid when Require of the principals
+// Of endorsed it, each by a different peer.
+//
+//	policy:
+//	  require: 2
+//	  of:
+//	    - {org: Org1, role: peer}
+//	    - {org: Org2}
+//	    - {org: Org3}
+type Policy struct {
+	Require int         `yaml:"require"`
+	Of      []Principal `yaml:"of"`
+}
+
+// anyMember is the policy of a member of any of orgs
+func anyMember(orgs []string) *Policy {
+	p := &Policy{Require: 1}
+	for _, org := range orgs {
+		p.Of = append(p.Of, Principal{Org: org, Role: "member"})
+	}
+	return p
+}
+
+// validate fills in the defaults of p and checks that the peers of orgs can satisfy it
+func (p *Policy) validate(orgs []string) error {
+	if len(p.Of) == 0 {
+		return errors.New("no principal")
+	}
+	if p.Require == 0 {
+		p.Require = 1
+	}
+	if p.Require < 0 || p.Require > len(p.Of) {
+		return errors.Errorf("requires %d of %d principals", p.Require, len(p.Of))
+	}
+	for i := range p.Of {
+		principal := &p.Of[i]
+		if principal.Role == "" {
+			principal.Role = "member"
+		}
+		if _, ok := endorsingRoles[principal.Role]; !ok {
+			return errors.Errorf("role %s of %s cannot endorse, only member and peer do", principal.Role, principal.Org)
+		}
+		if !contains(orgs, principal.Org) {
+			return errors.Errorf("%s is not an org of the channel", principal.Org)
+		}
+		for _, other := range p.Of[:i] {
+			if other == *principal {
+				return errors.Errorf("%s %s is counted twice", principal.Org, principal.Role)
+			}
+		}
+	}
+	return nil
+}
+
+// Envelope returns p as instantiate and upgrade take it, with the MSPs of the orgs of m
+func (p *Policy) Envelope(m *Manifest) (*cb.SignaturePolicyEnvelope, error) {
+	principals := make([]*msp.MSPPrincipal, len(p.Of))
+	rules := make([]*cb.SignaturePolicy, len(p.Of))
+	for i, principal := range p.Of {
+		role, err := proto.Marshal(&msp.MSPRole{Role: endorsingRoles[principal.Role], MspIdentifier: m.mspID(principal.Org)})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to marshal role")
+		}
+		principals[i] = &msp.MSPPrincipal{PrincipalClassification: msp.MSPPrincipal_ROLE, Principal: role}
+		rules[i] = cauthdsl.SignedBy(int32(i))
+	}
+	return &cb.SignaturePolicyEnvelope{Version: 0, Rule: cauthdsl.NOutOf(int32(p.Require), rules), Identities: principals}, nil
+}
+
+// endorsers picks peers whose endorsements satisfy p among peers, by org. It prefers
+// the peers picked already, to satisfy several policies with the same endorsements.
+func (p *Policy) endorsers(peers map[string][]fab.Peer, picked []fab.Peer) ([]fab.Peer, error) {
+	// every peer of an org satisfies its principals, so a principal only needs a peer of
+	// its org no other principal counts
+	used := make(map[fab.Peer]bool)
+	satisfied := 0
+	for _, principal := range p.Of {
+		if satisfied == p.Require {
+			break
+		}
+		candidates := append(orgPeers(picked, peers[principal.Org]), peers[principal.Org]...)
+		for _, candidate := range candidates {
+			if !used[candidate] {
+				used[candidate] = true
+				satisfied++
+				if !containsPeer(picked, candidate) {
+					picked = append(picked, candidate)
+				}
+				break
+			}
+		}
+	}
+	if satisfied < p.Require {
+		return nil, errors.Errorf("the peers configured satisfy %d of the %d principals required", satisfied, p.Require)
+	}
+	return picked, nil
+}
+
+// orgPeers returns the peers of among that are peers of org
+func orgPeers(among, org []fab.Peer) []fab.Peer {
+	var peers []fab.Peer
+	for _, peer := range among {
+		if containsPeer(org, peer) {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+func containsPeer(peers []fab.Peer, peer fab.Peer) bool {
+	for _, p := range peers {
+		if p == peer {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
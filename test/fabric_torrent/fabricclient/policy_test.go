@@ -0,0 +1,102 @@
+package fabricclient
+
+import (
+	"testing"
+
+	fab "github.com/hyperledger/fabric-sdk-go/api/apifabclient"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	orgs := []string{"Org1", "Org2", "Org3"}
+	tests := []struct {
+		name   string
+		policy Policy
+		ok     bool
+	}{
+		{"one of one", Policy{Of: []Principal{{Org: "Org1"}}}, true},
+		{"two of three", Policy{Require: 2, Of: []Principal{{Org: "Org1", Role: "peer"}, {Org: "Org2"}, {Org: "Org3"}}}, true},
+		{"all", Policy{Require: 2, Of: []Principal{{Org: "Org1"}, {Org: "Org2"}}}, true},
+		{"same org, other role", Policy{Require: 2, Of: []Principal{{Org: "Org1"}, {Org: "Org1", Role: "peer"}}}, true},
+		{"no principal", Policy{Require: 1}, false},
+		{"more than principals", Policy{Require: 3, Of: []Principal{{Org: "Org1"}, {Org: "Org2"}}}, false},
+		{"negative", Policy{Require: -1, Of: []Principal{{Org: "Org1"}}}, false},
+		{"admin", Policy{Of: []Principal{{Org: "Org1", Role: "admin"}}}, false},
+		{"client", Policy{Of: []Principal{{Org: "Org1", Role: "client"}}}, false},
+		{"unknown org", Policy{Of: []Principal{{Org: "Org4"}}}, false},
+		{"counted twice", Policy{Of: []Principal{{Org: "Org1"}, {Org: "Org1", Role: "member"}}}, false},
+	}
+	for _, test := range tests {
+		err := test.policy.validate(orgs)
+		if test.ok && err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+
+	p := Policy{Of: []Principal{{Org: "Org1"}, {Org: "Org2", Role: "peer"}}}
+	if err := p.validate(orgs); err != nil {
+		t.Fatal(err)
+	}
+	if p.Require != 1 || p.Of[0].Role != "member" || p.Of[1].Role != "peer" {
+		t.Errorf("defaults filled in as %+v", p)
+	}
+}
+
+// testPeer stands for a peer, endorsers only compares them
+type testPeer struct {
+	fab.Peer
+	name string
+}
+
+func (p *testPeer) String() string {
+	return p.name
+}
+
+func TestPolicyEndorsers(t *testing.T) {
+	a1, a2, b1, c1 := &testPeer{name: "a1"}, &testPeer{name: "a2"}, &testPeer{name: "b1"}, &testPeer{name: "c1"}
+	peers := map[string][]fab.Peer{
+		"Org1": {a1, a2},
+		"Org2": {b1},
+		"Org3": {c1},
+	}
+	tests := []struct {
+		name      string
+		policy    Policy
+		picked    []fab.Peer
+		endorsers []fab.Peer
+	}{
+		{"one of one", Policy{Require: 1, Of: []Principal{{Org: "Org2"}}}, nil, []fab.Peer{b1}},
+		{"first principals", Policy{Require: 2, Of: []Principal{{Org: "Org1"}, {Org: "Org2"}, {Org: "Org3"}}}, nil, []fab.Peer{a1, b1}},
+		{"two peers of an org", Policy{Require: 2, Of: []Principal{{Org: "Org1"}, {Org: "Org1", Role: "peer"}}}, nil, []fab.Peer{a1, a2}},
+		{"picked preferred", Policy{Require: 1, Of: []Principal{{Org: "Org1"}}}, []fab.Peer{a2}, []fab.Peer{a2}},
+		{"picked kept", Policy{Require: 1, Of: []Principal{{Org: "Org1"}}}, []fab.Peer{c1}, []fab.Peer{c1, a1}},
+		{"org without peers", Policy{Require: 1, Of: []Principal{{Org: "Org4"}, {Org: "Org3"}}}, nil, []fab.Peer{c1}},
+		{"unsatisfiable", Policy{Require: 2, Of: []Principal{{Org: "Org2"}, {Org: "Org2", Role: "peer"}}}, nil, nil},
+		{"no peers", Policy{Require: 1, Of: []Principal{{Org: "Org4"}}}, nil, nil},
+	}
+	for _, test := range tests {
+		endorsers, err := test.policy.endorsers(peers, test.picked)
+		if test.endorsers == nil {
+			if err == nil {
+				t.Errorf("%s: picked %v, expected an error", test.name, endorsers)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if len(endorsers) != len(test.endorsers) {
+			t.Errorf("%s: picked %v, expected %v", test.name, endorsers, test.endorsers)
+			continue
+		}
+		for i := range endorsers {
+			if endorsers[i] != test.endorsers[i] {
+				t.Errorf("%s: picked %v, expected %v", test.name, endorsers, test.endorsers)
+				break
+			}
+		}
+	}
+}
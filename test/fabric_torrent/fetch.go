@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/seeding"
+)
+
+// dhtNode returns the address of the DHT bootstrap node registered in dht_server,
+// waiting for it to be registered
+func dhtNode(chClient chclient.ChannelClient) string {
+	for {
+		response, err := chClient.Execute(chclient.Request{ChaincodeID: fabricclient.DHTCC, Fcn: "invoke", Args: [][]byte{[]byte("query"), []byte("dht_server")}})
+		if err == nil && len(response.Payload) > 0 {
+			fmt.Println("finally get the server address: " + string(response.Payload))
+			return string(response.Payload)
+		}
+		fmt.Println("another try in getting server address")
+		time.Sleep(20 * time.Second)
+	}
+}
+
+// fetchCommand implements
+//
+//	fabric_torrent fetch [flags] [id...]
+//
+// It downloads the files the subscriptions select and the files given by id, and keeps
+// running to seed them, to fetch what is published later and to serve the console.
+// Entries of origindataPath are published like serve does, without answering requests.
+func fetchCommand(args []string) error {
+	network := fabricclient.Flags()
+	subscriptionFile := flag.String("subscriptions", "subscriptions.yaml", "rules selecting the files fetched automatically")
+	httpAddr := flag.String("http", "", "address of a read-only http view of the catalog, empty disables it")
+	listen := flag.String("listen", seeding.ListenAddr, "address to accept peers on")
+	quota := quotaFlags()
+	keys := keyDBFlags()
+	flag.Int64Var(&pieceLength, "piece-length", 0, "torrent piece length in bytes, 0 chooses it from the file size")
+	flag.BoolVar(&purgeDeleted, "purge-deleted", false, "delete ciphertext and keys of files unpublished because they were removed from origindata")
+	flag.CommandLine.Parse(args)
+	if err := keys.unlock(); err != nil {
+		return err
+	}
+	defer keyStore.Close()
+
+	subs, err := loadSubscriptions(*subscriptionFile)
+	if err != nil {
+		return err
+	}
+	chClient, owner, err := connect(network)
+	if err != nil {
+		return err
+	}
+
+	clientConfig := seeding.NewConfig(encryptdataPath, *listen, []string{dhtNode(chClient)})
+	quota.apply(&clientConfig)
+	bannedPeers := loadPeerBlocklist("banned_peers.json")
+	clientConfig.IPBlocklist = bannedPeers
+	torrentClient, err := torrent.NewClient(&clientConfig)
+	if err != nil {
+		return err
+	}
+	defer torrentClient.Close()
+	downloadSlots = newTorrentSlots(quota.MaxTorrents)
+	budget = newDiskBudget(quota.DiskBudget, encryptdataPath, torrentClient)
+	go budget.run(time.Minute)
+	downloads = newDownloadManager(torrentClient, bannedPeers)
+	go func() {
+		for result := range downloads.Results {
+			if result.Status == downloadCompleted {
+				budget.enforce()
+			}
+		}
+	}()
+
+	files := newFileCatalog()
+	// registered before the refresh so no file is missed in between
+	catalogEvents, err := fabricclient.Events(chClient, fabricclient.CatalogCC, "createFile|updateFile|deleteFile")
+	if err != nil {
+		return err
+	}
+	go watchCatalog(catalogEvents, torrentClient, files, subs)
+	//retrive all files available and fetch the subscribed ones
+	all, err := files.refresh(chClient)
+	if err != nil {
+		fmt.Println(err)
+	}
+	for _, file := range all {
+		if subs.wants(file) {
+			fmt.Println("fetching", file.Name)
+			download(torrentClient, file)
+		}
+	}
+	for _, id := range flag.Args() {
+		fetch(torrentClient, files, id)
+	}
+	go readCommands(os.Stdin, chClient, torrentClient, files, owner)
+	if *httpAddr != "" {
+		exchange, err := newKeyExchange(chClient)
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			go func() {
+				fmt.Println(serveCatalog(*httpAddr, &catalogServer{client: torrentClient, files: files, exchange: exchange, user: owner}))
+			}()
+		}
+	}
+
+	queue := newIngestQueue(filepath.Join(encryptdataPath, ingestStateFile), ingestEntry(chClient, torrentClient, owner))
+	if err := watchOrigindata(queue); err != nil {
+		return err
+	}
+
+	select {}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/radovskyb/watcher"
+)
+
+// Operations of an ingest job
+const (
+	ingestPublish = "publish"
+	ingestRemove  = "remove"
+)
+
+const (
+	// ingestStateFile persists the queue in encryptdataPath, which the watcher does not see
+	ingestStateFile = ".ingest.json"
+	// ingestSettle is how long an entry must keep its size and modification time
+	// before it is considered fully written
+	ingestSettle      = 2 * time.Second
+	ingestMaxBackoff  = 10 * time.Minute
+	ingestMaxAttempts = 20
+)
+
+// ingestJob is a pending change of a top-level entry of origindataPath, the unit that is
+// published as one torrent. Changes anywhere inside a directory publish the directory again.
+type ingestJob struct {
+	Name     string    `json:"name"`
+	Op       string    `json:"op"`
+	Attempts int       `json:"attempts"`
+	NextTry  time.Time `json:"nextTry"`
+	// Size and ModTime are the state of the entry when it was last looked at
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// ingestQueue feeds the changes seen by the origindata watcher to handle, one entry at
+// a time. Jobs are persisted so changes seen before a crash are processed on restart.
+type ingestQueue struct {
+	mu        sync.Mutex
+	stateFile string
+	jobs      map[string]*ingestJob
+	handle    func(job ingestJob) error
+	wake      chan struct{}
+}
+
+func newIngestQueue(stateFile string, handle func(job ingestJob) error) *ingestQueue {
+	q := &ingestQueue{
+		stateFile: stateFile,
+		jobs:      make(map[string]*ingestJob),
+		handle:    handle,
+		wake:      make(chan struct{}, 1),
+	}
+	if content, err := ioutil.ReadFile(stateFile); err == nil {
+		json.Unmarshal(content, &q.jobs)
+	}
+	return q
+}
+
+// save must be called with q.mu held
+func (q *ingestQueue) save() {
+	content, err := json.Marshal(q.jobs)
+	if err != nil {
+		return
+	}
+	tmp := q.stateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0600); err != nil {
+		fmt.Println("unable to save ingest queue: ", err)
+		return
+	}
+	if err := os.Rename(tmp, q.stateFile); err != nil {
+		fmt.Println("unable to save ingest queue: ", err)
+	}
+}
+
+// add queues op for the entry name, replacing any pending job of it
+func (q *ingestQueue) add(name, op string) {
+	q.mu.Lock()
+	q.jobs[name] = &ingestJob{Name: name, Op: op, NextTry: time.Now().Add(ingestSettle)}
+	q.save()
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// entryName returns the top-level entry of origindataPath path belongs to and whether
+// path is that entry itself
+func entryName(path string) (name string, top bool, ok bool) {
+	root, err := filepath.Abs(origindataPath)
+	if err != nil {
+		return "", false, false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false, false
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false, false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if strings.HasPrefix(parts[0], ".") {
+		return "", false, false
+	}
+	if len(parts) == 1 && isSidecar(parts[0]) {
+		// new metadata publishes the entry again
+		return strings.TrimSuffix(parts[0], metaSuffix), false, true
+	}
+	return parts[0], len(parts) == 1, true
+}
+
+// event queues the jobs a watcher event calls for
+func (q *ingestQueue) event(event watcher.Event) {
+	switch event.Op {
+	case watcher.Create, watcher.Write:
+		q.changed(event.Path)
+	case watcher.Remove:
+		q.removed(event.Path)
+	case watcher.Rename, watcher.Move:
+		q.removed(event.OldPath)
+		q.changed(event.Path)
+	}
+}
+
+func (q *ingestQueue) changed(path string) {
+	if isMetaDefaults(path) {
+		q.defaultsChanged()
+		return
+	}
+	if name, _, ok := entryName(path); ok {
+		q.add(name, ingestPublish)
+	}
+}
+
+func (q *ingestQueue) removed(path string) {
+	if isMetaDefaults(path) {
+		q.defaultsChanged()
+		return
+	}
+	name, top, ok := entryName(path)
+	if !ok {
+		return
+	}
+	if top {
+		q.add(name, ingestRemove)
+	} else {
+		// a file inside a published directory
+		q.add(name, ingestPublish)
+	}
+}
+
+// isMetaDefaults reports whether path is the metaDefaults file of origindataPath
+func isMetaDefaults(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	defaults, err := filepath.Abs(filepath.Join(origindataPath, metaDefaults))
+	return err == nil && abs == defaults
+}
+
+// defaultsChanged publishes again every entry of origindataPath, the defaults of
+// metaDefaults apply to all of them
+func (q *ingestQueue) defaultsChanged() {
+	fi, err := ioutil.ReadDir(origindataPath)
+	if err != nil {
+		fmt.Println("unable to list", origindataPath, err)
+		return
+	}
+	for _, x := range fi {
+		if !strings.HasPrefix(x.Name(), ".") && !isSidecar(x.Name()) {
+			q.add(x.Name(), ingestPublish)
+		}
+	}
+}
+
+// entryState returns the total size and the latest modification time below path
+func entryState(path string) (size int64, modTime time.Time, err error) {
+	err = filepath.Walk(path, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		if fi.ModTime().After(modTime) {
+			modTime = fi.ModTime()
+		}
+		return nil
+	})
+	return
+}
+
+// settled reports whether the entry of job stopped changing, recording its current state otherwise
+func (q *ingestQueue) settled(job *ingestJob) bool {
+	if job.Op != ingestPublish {
+		return true
+	}
+	size, modTime, err := entryState(filepath.Join(origindataPath, job.Name))
+	if err != nil {
+		// still being moved into place or already gone again, the handler reports it
+		return true
+	}
+	if size == job.Size && modTime.Equal(job.ModTime) && time.Since(modTime) >= ingestSettle {
+		return true
+	}
+	job.Size, job.ModTime = size, modTime
+	job.NextTry = time.Now().Add(ingestSettle)
+	return false
+}
+
+// next returns a copy of a job that is due, or how long to wait for one
+func (q *ingestQueue) next() (*ingestJob, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	wait := time.Hour
+	for _, job := range q.jobs {
+		if d := time.Until(job.NextTry); d > 0 {
+			if d < wait {
+				wait = d
+			}
+			continue
+		}
+		if !q.settled(job) {
+			q.save()
+			if ingestSettle < wait {
+				wait = ingestSettle
+			}
+			continue
+		}
+		j := *job
+		return &j, 0
+	}
+	return nil, wait
+}
+
+// done removes job from the queue, unless the entry changed again meanwhile, or
+// schedules its retry with exponential backoff
+func (q *ingestQueue) done(job *ingestJob, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	current, ok := q.jobs[job.Name]
+	if !ok || current.Op != job.Op || !current.NextTry.Equal(job.NextTry) {
+		return
+	}
+	if err == nil {
+		delete(q.jobs, job.Name)
+		q.save()
+		return
+	}
+	current.Attempts++
+	if current.Attempts >= ingestMaxAttempts {
+		fmt.Println("giving up on", job.Op, job.Name, "after", current.Attempts, "attempts:", err)
+		delete(q.jobs, job.Name)
+		q.save()
+		return
+	}
+	backoff := time.Second << uint(current.Attempts)
+	if backoff > ingestMaxBackoff || backoff <= 0 {
+		backoff = ingestMaxBackoff
+	}
+	current.NextTry = time.Now().Add(backoff)
+	fmt.Println(job.Op, job.Name, "failed, retrying in", backoff, ":", err)
+	q.save()
+}
+
+// run processes the queue until the process exits
+func (q *ingestQueue) run() {
+	for {
+		job, wait := q.next()
+		if job == nil {
+			select {
+			case <-q.wake:
+			case <-time.After(wait):
+			}
+			continue
+		}
+		q.done(job, q.handle(*job))
+	}
+}
+
+// watchOrigindata queues every change below origindataPath and processes the queue with q
+func watchOrigindata(q *ingestQueue) error {
+	w := watcher.New()
+	w.IgnoreHiddenFiles(true)
+	go func() {
+		for {
+			select {
+			case event := <-w.Event:
+				q.event(event)
+			case err := <-w.Error:
+				fmt.Println("origindata watcher: ", err)
+			case <-w.Closed:
+				return
+			}
+		}
+	}()
+	go q.run()
+	if err := w.AddRecursive(origindataPath); err != nil {
+		return err
+	}
+	go func() {
+		if err := w.Start(time.Millisecond * 100); err != nil {
+			fmt.Println("origindata watcher: ", err)
+		}
+	}()
+	return nil
+}
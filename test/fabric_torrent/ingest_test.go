@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// inTempDir runs the test in a fresh directory holding origindataPath
+func inTempDir(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "ingest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, origindataPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestEntryName(t *testing.T) {
+	tests := []struct {
+		path string
+		name string
+		top  bool
+		ok   bool
+	}{
+		{"origindata/a.txt", "a.txt", true, true},
+		{"origindata/dir", "dir", true, true},
+		{"origindata/dir/sub/b.txt", "dir", false, true},
+		{"origindata/a.txt" + metaSuffix, "a.txt", false, true},
+		{"origindata/dir/c" + metaSuffix, "dir", false, true},
+		{"origindata/.hidden", "", false, false},
+		{"origindata", "", false, false},
+		{"encryptdata/a.txt", "", false, false},
+	}
+	for _, test := range tests {
+		name, top, ok := entryName(test.path)
+		if name != test.name || top != test.top || ok != test.ok {
+			t.Errorf("%s: %q, %v, %v, expected %q, %v, %v", test.path, name, top, ok, test.name, test.top, test.ok)
+		}
+	}
+}
+
+func TestIngestMetaDefaults(t *testing.T) {
+	defer inTempDir(t)()
+	for _, path := range []string{"a.txt", "a.txt" + metaSuffix, "dir/b.txt", ".hidden"} {
+		path = filepath.Join(origindataPath, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("content"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name   string
+		update func(q *ingestQueue, path string)
+	}{
+		{"changed", (*ingestQueue).changed},
+		{"removed", (*ingestQueue).removed},
+	}
+	for _, test := range tests {
+		q := newIngestQueue(ingestStateFile, nil)
+		test.update(q, filepath.Join(origindataPath, metaDefaults))
+		if len(q.jobs) != 2 || q.jobs["a.txt"] == nil || q.jobs["dir"] == nil {
+			t.Errorf("%s defaults: queued %v, expected a.txt and dir", test.name, q.jobs)
+		}
+		for _, job := range q.jobs {
+			if job.Op != ingestPublish {
+				t.Errorf("%s defaults: %s queued for %s", test.name, job.Name, job.Op)
+			}
+		}
+		os.Remove(ingestStateFile)
+	}
+}
+
+func TestIngestSettle(t *testing.T) {
+	defer inTempDir(t)()
+	path := filepath.Join(origindataPath, "a.txt")
+	if err := ioutil.WriteFile(path, []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	q := newIngestQueue(ingestStateFile, nil)
+
+	tests := []struct {
+		name    string
+		change  func() error
+		settled bool
+	}{
+		// the first look records the state of the entry
+		{"first look", nil, false},
+		{"unchanged", nil, true},
+		{"grown", func() error { return ioutil.WriteFile(path, []byte("more content"), 0600) }, false},
+		{"written just now", nil, false},
+		{"written long ago", func() error { return os.Chtimes(path, old, old) }, false},
+		{"unchanged since", nil, true},
+	}
+	q.add("a.txt", ingestPublish)
+	for _, test := range tests {
+		if test.change != nil {
+			if err := test.change(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// the job is due
+		q.jobs["a.txt"].NextTry = time.Now().Add(-time.Second)
+		job, wait := q.next()
+		if settled := job != nil; settled != test.settled {
+			t.Fatalf("%s: settled %v, expected %v", test.name, settled, test.settled)
+		}
+		if job == nil && (wait <= 0 || wait > ingestSettle) {
+			t.Errorf("%s: waits %s for an unsettled entry", test.name, wait)
+		}
+	}
+
+	q.add("gone", ingestRemove)
+	q.jobs["a.txt"].NextTry = time.Now().Add(time.Hour)
+	q.jobs["gone"].NextTry = time.Now().Add(-time.Second)
+	if job, _ := q.next(); job == nil || job.Name != "gone" {
+		t.Errorf("a removal is due at once, got %v", job)
+	}
+}
+
+func TestIngestBackoff(t *testing.T) {
+	defer inTempDir(t)()
+	failure := errors.New("failure")
+	tests := []struct {
+		attempts int
+		backoff  time.Duration
+		dropped  bool
+	}{
+		{0, 2 * time.Second, false},
+		{1, 4 * time.Second, false},
+		{4, 32 * time.Second, false},
+		{8, 512 * time.Second, false},
+		{9, ingestMaxBackoff, false},
+		{ingestMaxAttempts - 2, ingestMaxBackoff, false},
+		{ingestMaxAttempts - 1, 0, true},
+	}
+	for _, test := range tests {
+		q := newIngestQueue(ingestStateFile, nil)
+		q.add("a.txt", ingestPublish)
+		q.jobs["a.txt"].Attempts = test.attempts
+		job := *q.jobs["a.txt"]
+		before := time.Now()
+		q.done(&job, failure)
+		current, ok := q.jobs["a.txt"]
+		if ok == test.dropped {
+			t.Errorf("after %d attempts: dropped %v, expected %v", test.attempts+1, !ok, test.dropped)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if current.Attempts != test.attempts+1 {
+			t.Errorf("after %d attempts: counted %d", test.attempts+1, current.Attempts)
+		}
+		if backoff := current.NextTry.Sub(before); backoff < test.backoff || backoff > test.backoff+time.Second {
+			t.Errorf("after %d attempts: retries in %s, expected %s", test.attempts+1, backoff, test.backoff)
+		}
+	}
+}
+
+func TestIngestDone(t *testing.T) {
+	defer inTempDir(t)()
+	q := newIngestQueue(ingestStateFile, nil)
+
+	q.add("a.txt", ingestPublish)
+	job := *q.jobs["a.txt"]
+	q.done(&job, nil)
+	if _, ok := q.jobs["a.txt"]; ok {
+		t.Error("a job done is still queued")
+	}
+
+	// a change seen while the job ran queues it again
+	q.add("a.txt", ingestPublish)
+	job = *q.jobs["a.txt"]
+	time.Sleep(time.Millisecond)
+	q.add("a.txt", ingestPublish)
+	q.done(&job, nil)
+	if _, ok := q.jobs["a.txt"]; !ok {
+		t.Error("the job of a change seen meanwhile was dropped")
+	}
+	q.add("b.txt", ingestPublish)
+	job = *q.jobs["b.txt"]
+	q.add("b.txt", ingestRemove)
+	q.done(&job, errors.New("failure"))
+	if current := q.jobs["b.txt"]; current.Op != ingestRemove || current.Attempts != 0 {
+		t.Errorf("the failure of a replaced job changed its replacement: %+v", current)
+	}
+}
+
+func TestIngestReload(t *testing.T) {
+	defer inTempDir(t)()
+	q := newIngestQueue(ingestStateFile, nil)
+	q.add("a.txt", ingestPublish)
+	q.add("dir", ingestRemove)
+	q.add("b.txt", ingestPublish)
+	job := *q.jobs["b.txt"]
+	q.done(&job, errors.New("failure"))
+
+	reloaded := newIngestQueue(ingestStateFile, nil)
+	if len(reloaded.jobs) != len(q.jobs) {
+		t.Fatalf("reloaded %d jobs, expected %d", len(reloaded.jobs), len(q.jobs))
+	}
+	for name, job := range q.jobs {
+		got, ok := reloaded.jobs[name]
+		if !ok {
+			t.Errorf("%s was not reloaded", name)
+			continue
+		}
+		if got.Name != job.Name || got.Op != job.Op || got.Attempts != job.Attempts || !got.NextTry.Equal(job.NextTry) {
+			t.Errorf("%s reloaded as %+v, expected %+v", name, got, job)
+		}
+	}
+	if _, err := os.Stat(ingestStateFile + ".tmp"); !os.IsNotExist(err) {
+		t.Error("the temporary state file was left")
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/keystore"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// keyDBConfig says where the keys are kept and where the secret protecting them comes from
+type keyDBConfig struct {
+	Backend        string
+	Path           string
+	PassphraseFile string
+	MSPKey         string
+	PKCS11         keystore.PKCS11Config
+}
+
+// keyStore is opened by keyDBConfig.unlock and stays open for the life of the process
+var keyStore keystore.KeyStore
+
+// keyDBFlags registers the key store command line flags, call before flag.Parse
+func keyDBFlags() *keyDBConfig {
+	k := &keyDBConfig{}
+	flag.StringVar(&k.Backend, "keystore", "leveldb", "where file keys are kept: leveldb, file or pkcs11")
+	flag.StringVar(&k.Path, "keystore-path", "", "leveldb directory or JSON file of the keys, key.db or keys.json by default")
+	flag.StringVar(&k.PassphraseFile, "keydb-passphrase-file", "", "file holding the passphrase of key.db (the PIN with pkcs11), KEYDB_PASSPHRASE or a prompt otherwise")
+	flag.StringVar(&k.MSPKey, "keydb-msp-key", "", "private key (or keystore directory) of the node's MSP to derive the key.db master key from")
+	flag.StringVar(&k.PKCS11.Library, "pkcs11-lib", "/usr/lib/softhsm/libsofthsm2.so", "PKCS#11 module")
+	flag.StringVar(&k.PKCS11.Token, "pkcs11-token", "ForFabric", "label of the PKCS#11 token")
+	flag.StringVar(&k.PKCS11.KeyLabel, "pkcs11-key-label", "fabric_torrent_kek", "label of the AES key wrapping file keys in the token")
+	return k
+}
+
+// secret returns the bytes the master key is derived from, or the PIN of the PKCS#11 token
+func (k *keyDBConfig) secret() ([]byte, error) {
+	if k.MSPKey != "" {
+		return mspSecret(k.MSPKey)
+	}
+	if k.PassphraseFile != "" {
+		b, err := ioutil.ReadFile(k.PassphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strings.TrimRight(string(b), "\r\n")), nil
+	}
+	if p := os.Getenv("KEYDB_PASSPHRASE"); p != "" {
+		return []byte(p), nil
+	}
+	// a terminal does not echo the passphrase, a pipe gives its first line
+	if fd := int(os.Stdin.Fd()); terminal.IsTerminal(fd) {
+		fmt.Print("key.db passphrase: ")
+		p, err := terminal.ReadPassword(fd)
+		fmt.Println()
+		return p, err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
+
+// mspSecret derives the secret of key.db from the private key of the node's MSP
+func mspSecret(path string) ([]byte, error) {
+	key, err := loadMSPKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return key.D.Bytes(), nil
+}
+
+// loadMSPKey reads an ECDSA private key in PKCS#8 PEM, as found in msp/keystore.
+// A directory must hold exactly one key.
+func loadMSPKey(path string) (*ecdsa.PrivateKey, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		matches, err := filepath.Glob(filepath.Join(path, "*_sk"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) != 1 {
+			return nil, errors.Errorf("expected one private key in %s, found %d", path, len(matches))
+		}
+		path = matches[0]
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.Errorf("%s is not PEM", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse MSP private key")
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.Errorf("%s is not an ECDSA key", path)
+	}
+	return ecKey, nil
+}
+
+// unlock opens the key store and derives the secret protecting it. Keys can only be
+// read after unlock.
+func (k *keyDBConfig) unlock() error {
+	secret, err := k.secret()
+	if err != nil {
+		return err
+	}
+	store, err := keystore.Open(keystore.Config{Backend: k.Backend, Path: k.Path, PKCS11: k.PKCS11}, secret)
+	if err != nil {
+		return err
+	}
+	keyStore = store
+	if err := migrateKeyStore(store); err != nil {
+		store.Close()
+		return err
+	}
+	return nil
+}
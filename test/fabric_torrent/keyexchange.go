@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/keywrap"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/shamir"
+	"github.com/pkg/errors"
+)
+
+// secretTimeout is how long the owner of a file has to answer a request
+var secretTimeout = 2 * time.Minute
+
+// errRevoked is returned for requests the owner revoked because the file key was rotated
+var errRevoked = errors.New("request revoked, the file has a new key")
+
+// keyAnswer is one answer to a request: the whole key from the owner, a share of it
+// from a share holder, or neither when the request was revoked
+type keyAnswer struct {
+	from   string
+	secret string
+	share  string
+}
+
+// keyExchange obtains file keys from their owners through the keyExchange chaincode.
+// Requests carry a public key of this client, owners answer with the file key wrapped for
+// it. Keys split among share holders are recovered from enough of their answers instead.
+type keyExchange struct {
+	exchange *fabricclient.Exchange
+	priv     *ecdsa.PrivateKey
+
+	mu      sync.Mutex
+	waiting map[string]chan keyAnswer
+	// requesting counts the requests sent whose txID is not known yet, answers to
+	// unknown txIDs are only kept in early meanwhile, until secretTimeout
+	requesting int
+	early      map[string]*earlyAnswers
+	keys       map[catalog.FileKey]cachedKey
+}
+
+// cachedKey is the key of a file received for the version published as infohash id.
+// updateFile publishes a new version under another id, which invalidates it.
+type cachedKey struct {
+	id  string
+	key []byte
+}
+
+// earlyAnswers are the answers to a txID nobody waits for yet
+type earlyAnswers struct {
+	answers []keyAnswer
+	first   time.Time
+}
+
+func newKeyExchange(chClient chclient.ChannelClient) (*keyExchange, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	k := &keyExchange{
+		exchange: fabricclient.NewExchange(chClient),
+		priv:     priv,
+		waiting:  make(map[string]chan keyAnswer),
+		early:    make(map[string]*earlyAnswers),
+		keys:     make(map[catalog.FileKey]cachedKey),
+	}
+	events, err := fabricclient.Events(chClient, fabricclient.ExchangeCC, "respondSecret|respondShare|revokeSecret")
+	if err != nil {
+		return nil, err
+	}
+	go k.listen(events)
+	return k, nil
+}
+
+func (k *keyExchange) listen(events <-chan *chclient.CCEvent) {
+	for ccEvent := range events {
+		switch ccEvent.EventName {
+		case "respondSecret":
+			var message catalog.ResponseMessage
+			if err := json.Unmarshal(ccEvent.Payload, &message); err != nil {
+				continue
+			}
+			for _, txID := range message.TxID {
+				k.deliver(txID, keyAnswer{from: message.From, secret: message.Secret})
+			}
+		case "respondShare":
+			var message catalog.ShareMessage
+			if err := json.Unmarshal(ccEvent.Payload, &message); err != nil {
+				continue
+			}
+			k.deliver(message.TxID, keyAnswer{from: message.From, share: message.Share})
+		case "revokeSecret":
+			var message catalog.RevocationMessage
+			if err := json.Unmarshal(ccEvent.Payload, &message); err != nil {
+				continue
+			}
+			for _, txID := range message.TxID {
+				k.deliver(txID, keyAnswer{})
+			}
+		}
+	}
+}
+
+func (k *keyExchange) deliver(txID string, answer keyAnswer) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for id, early := range k.early {
+		if time.Since(early.first) > secretTimeout {
+			delete(k.early, id)
+		}
+	}
+	if ch, ok := k.waiting[txID]; ok {
+		select {
+		case ch <- answer:
+		default:
+		}
+		return
+	}
+	// the answer may arrive before the request being sent returned its txID, answers to
+	// requests of other clients are dropped
+	if k.requesting == 0 {
+		return
+	}
+	early, ok := k.early[txID]
+	if !ok {
+		early = &earlyAnswers{first: time.Now()}
+		k.early[txID] = early
+	}
+	early.answers = append(early.answers, answer)
+}
+
+// request sends a request for the key of file and subscribes to its answers, including
+// those arriving before it returns
+func (k *keyExchange) request(file catalog.File) (string, chan keyAnswer, error) {
+	k.mu.Lock()
+	k.requesting++
+	k.mu.Unlock()
+	txID, err := k.exchange.Request(context.Background(), file.Key(), keywrap.EncodePublicKey(&k.priv.PublicKey))
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.requesting--
+	if err != nil {
+		return "", nil, err
+	}
+	ch := make(chan keyAnswer, 256)
+	if early, ok := k.early[txID]; ok {
+		for _, answer := range early.answers {
+			ch <- answer
+		}
+		delete(k.early, txID)
+	}
+	if k.requesting == 0 {
+		k.early = make(map[string]*earlyAnswers)
+	}
+	k.waiting[txID] = ch
+	return txID, ch, nil
+}
+
+func (k *keyExchange) unsubscribe(txID string) {
+	k.mu.Lock()
+	delete(k.waiting, txID)
+	k.mu.Unlock()
+}
+
+// shareConfig returns how the key of file is split, nil when only its owner has it
+func (k *keyExchange) shareConfig(file catalog.File) *catalog.ShareConfig {
+	config, err := k.exchange.ShareConfig(context.Background(), file.Key())
+	if err != nil {
+		return nil
+	}
+	return config
+}
+
+// await collects answers to txID from ch until the whole key or threshold shares arrived
+func (k *keyExchange) await(txID string, ch chan keyAnswer, threshold int) ([]byte, error) {
+	defer k.unsubscribe(txID)
+	timeout := time.After(secretTimeout)
+	shares := make(map[string][]byte)
+	for {
+		select {
+		case answer := <-ch:
+			switch {
+			case answer.secret != "":
+				return k.unwrap(answer.secret)
+			case answer.share != "":
+				share, err := k.unwrap(answer.share)
+				if err != nil {
+					fmt.Println("invalid share from", answer.from, err)
+					continue
+				}
+				shares[answer.from] = share
+				if threshold > 0 && len(shares) >= threshold {
+					var all [][]byte
+					for _, share := range shares {
+						all = append(all, share)
+					}
+					return shamir.Combine(all)
+				}
+			default:
+				return nil, errRevoked
+			}
+		case <-timeout:
+			return nil, errors.Errorf("no answer to request %s", txID)
+		}
+	}
+}
+
+func (k *keyExchange) unwrap(secret string) ([]byte, error) {
+	wrapped, err := hex.DecodeString(secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid secret")
+	}
+	key, err := keywrap.Unwrap(k.priv, wrapped)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unwrap secret")
+	}
+	return key, nil
+}
+
+// fileKey requests the key of file from its owner, or from its share holders, waits for
+// the answers and confirms them. The key is cached until a new version of file is seen.
+func (k *keyExchange) fileKey(file catalog.File) ([]byte, error) {
+	id := fileID(file)
+	k.mu.Lock()
+	cached, ok := k.keys[file.Key()]
+	if ok && cached.id != id {
+		delete(k.keys, file.Key())
+		ok = false
+	}
+	k.mu.Unlock()
+	if ok {
+		return cached.key, nil
+	}
+
+	threshold := 0
+	if config := k.shareConfig(file); config != nil {
+		threshold = config.Threshold
+	}
+	txID, ch, err := k.request(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in request secret")
+	}
+	key, err := k.await(txID, ch, threshold)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.exchange.Confirm(context.Background(), txID); err != nil {
+		fmt.Println("error in confirm secret", err)
+	}
+
+	k.mu.Lock()
+	k.keys[file.Key()] = cachedKey{id: id, key: key}
+	k.mu.Unlock()
+	return key, nil
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/cryptofile"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/keystore"
+	"github.com/pkg/errors"
+)
+
+// Keys are kept by the content they encrypt, the name it was published under and the
+// version of that name, so two versions of one file, even of the same content, or the
+// same content published under two names, each have their own entry:
+//
+//	file/<plaintext hash>/<name>/<version>   keyRecord
+//	name/<name>                              reference (<plaintext hash>/<name>/<version>) of the latest version of name
+//	infohash/<infohash>                      reference of the record of the torrent's content
+const (
+	recordPrefix   = "file/"
+	namePrefix     = "name/"
+	infoHashPrefix = "infohash/"
+)
+
+// keyRecord is what the key store keeps about the key of one file or directory
+type keyRecord struct {
+	ID        string    `json:"id"` // hex sha256 of the plaintext, see plaintextHash
+	Name      string    `json:"name"`
+	InfoHash  string    `json:"infohash,omitempty"`
+	Algorithm string    `json:"algorithm"` // empty when unknown for migrated keys
+	Version   int       `json:"version"`
+	Created   time.Time `json:"created"`
+	Key       []byte    `json:"key"`
+}
+
+// ref is the reference of r, the key of its entry after recordPrefix
+func (r keyRecord) ref() string {
+	return fmt.Sprintf("%s/%s/%d", r.ID, r.Name, r.Version)
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// plaintextHash returns the hex encoded sha256 of a file under origindataPath.
+// A directory hashes the list of its files with their relative paths and hashes.
+func plaintextHash(name string) (string, error) {
+	root := filepath.Join(origindataPath, name)
+	fi, err := os.Stat(root)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return hashFile(root)
+	}
+	h := sha256.New()
+	err = cryptofile.Walk(root, func(rel string) error {
+		sum, err := hashFile(filepath.Join(root, rel))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s %s\n", filepath.ToSlash(rel), sum)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func getRecord(ref string) (keyRecord, error) {
+	var r keyRecord
+	data, err := keyStore.Get(recordPrefix + ref)
+	if err != nil {
+		return r, err
+	}
+	err = json.Unmarshal(data, &r)
+	return r, err
+}
+
+// putRecord stores r and points its name and infohash at it
+func putRecord(r keyRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	ref := r.ref()
+	if err := keyStore.Put(recordPrefix+ref, data); err != nil {
+		return err
+	}
+	if err := keyStore.Put(namePrefix+r.Name, []byte(ref)); err != nil {
+		return err
+	}
+	if r.InfoHash != "" {
+		return keyStore.Put(infoHashPrefix+r.InfoHash, []byte(ref))
+	}
+	return nil
+}
+
+func recordByName(name string) (keyRecord, error) {
+	ref, err := keyStore.Get(namePrefix + name)
+	if err != nil {
+		return keyRecord{}, err
+	}
+	return getRecord(string(ref))
+}
+
+func recordByInfoHash(infoHash string) (keyRecord, error) {
+	ref, err := keyStore.Get(infoHashPrefix + strings.ToLower(infoHash))
+	if err != nil {
+		return keyRecord{}, err
+	}
+	return getRecord(string(ref))
+}
+
+// storeKey records the key name of origindataPath was just encrypted with as the next
+// version of name. The records of the previous versions are kept.
+func storeKey(name, algorithm string, key []byte) error {
+	id, err := plaintextHash(name)
+	if err != nil {
+		return err
+	}
+	r := keyRecord{ID: id, Name: name, Algorithm: algorithm, Version: 1, Created: time.Now(), Key: key}
+	if old, err := recordByName(name); err == nil {
+		r.Version = old.Version + 1
+	}
+	return putRecord(r)
+}
+
+// recordMagnet adds the infohash of the torrent published for name to its key record
+func recordMagnet(name, magnet string) error {
+	m, err := metainfo.ParseMagnetURI(magnet)
+	if err != nil {
+		return err
+	}
+	r, err := recordByName(name)
+	if err != nil {
+		return err
+	}
+	r.InfoHash = m.InfoHash.HexString()
+	return putRecord(r)
+}
+
+// migrateKeyStore moves keys stored by bare file name to records keyed by the hash of
+// the plaintext and the name. Keys whose plaintext is gone get an id derived from their
+// name.
+func migrateKeyStore(store keystore.KeyStore) error {
+	if err := migrateRecordRefs(store); err != nil {
+		return err
+	}
+	names, err := store.List()
+	if err != nil {
+		return err
+	}
+	migrated := 0
+	for _, name := range names {
+		if strings.HasPrefix(name, recordPrefix) || strings.HasPrefix(name, namePrefix) || strings.HasPrefix(name, infoHashPrefix) {
+			continue
+		}
+		key, err := store.Get(name)
+		if err != nil {
+			return err
+		}
+		id, err := plaintextHash(name)
+		if err != nil {
+			sum := sha256.Sum256([]byte("legacy\x00" + name))
+			id = hex.EncodeToString(sum[:])
+		}
+		r := keyRecord{ID: id, Name: name, Version: 1, Created: time.Now(), Key: key}
+		if err := putRecord(r); err != nil {
+			return err
+		}
+		if err := store.Delete(name); err != nil {
+			return err
+		}
+		migrated++
+	}
+	if migrated > 0 {
+		fmt.Println("migrated", migrated, "keys to content ids")
+	}
+	return nil
+}
+
+// migrateRecordRefs moves the records keyed by an earlier layout, the plaintext hash
+// alone or with the name, to their reference and repoints the entries pointing at them.
+// Records of the same content published under two names overwrote each other under the
+// plaintext hash; the entries of the name that lost its key are dropped, it must be
+// published again.
+func migrateRecordRefs(store keystore.KeyStore) error {
+	names, err := store.List()
+	if err != nil {
+		return err
+	}
+	records := make(map[string]keyRecord)
+	for _, entry := range names {
+		old := strings.TrimPrefix(entry, recordPrefix)
+		if old == entry || strings.Count(old, "/") > 1 {
+			continue
+		}
+		data, err := store.Get(entry)
+		if err != nil {
+			return err
+		}
+		var r keyRecord
+		if err := json.Unmarshal(data, &r); err != nil {
+			return errors.Wrapf(err, "corrupt key record %s", entry)
+		}
+		if err := store.Put(recordPrefix+r.ref(), data); err != nil {
+			return err
+		}
+		if err := store.Delete(entry); err != nil {
+			return err
+		}
+		records[old] = r
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	for _, entry := range names {
+		var matches func(r keyRecord) bool
+		switch {
+		case strings.HasPrefix(entry, namePrefix):
+			name := strings.TrimPrefix(entry, namePrefix)
+			matches = func(r keyRecord) bool { return r.Name == name }
+		case strings.HasPrefix(entry, infoHashPrefix):
+			infoHash := strings.TrimPrefix(entry, infoHashPrefix)
+			matches = func(r keyRecord) bool { return r.InfoHash == infoHash }
+		default:
+			continue
+		}
+		old, err := store.Get(entry)
+		if err != nil {
+			return err
+		}
+		r, ok := records[string(old)]
+		if !ok {
+			continue
+		}
+		if matches(r) {
+			err = store.Put(entry, []byte(r.ref()))
+		} else {
+			fmt.Println("the key of", entry, "was overwritten by", r.Name, "with the same content, publish it again")
+			err = store.Delete(entry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Println("migrated", len(records), "key records to references")
+	return nil
+}
+
+// deleteRecords removes every key record of name, whichever content it was encrypted
+// from, and the entries pointing at them
+func deleteRecords(name string) error {
+	names, err := keyStore.List()
+	if err != nil {
+		return err
+	}
+	refs := make(map[string]bool)
+	for _, entry := range names {
+		if !strings.HasPrefix(entry, recordPrefix) {
+			continue
+		}
+		r, err := getRecord(strings.TrimPrefix(entry, recordPrefix))
+		if err != nil || r.Name != name {
+			continue
+		}
+		if err := keyStore.Delete(entry); err != nil {
+			return err
+		}
+		refs[r.ref()] = true
+	}
+	for _, entry := range names {
+		if !strings.HasPrefix(entry, namePrefix) && !strings.HasPrefix(entry, infoHashPrefix) {
+			continue
+		}
+		ref, err := keyStore.Get(entry)
+		if err != nil || !refs[string(ref)] {
+			continue
+		}
+		if err := keyStore.Delete(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
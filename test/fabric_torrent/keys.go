@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/pkg/errors"
+)
+
+const keysUsage = "usage: keys [flags] list | export <file> | import <file> | recover <owner>"
+
+// keysCommand implements
+//
+//	fabric_torrent keys [flags] list
+//	fabric_torrent keys [flags] export <file>
+//	fabric_torrent keys [flags] import <file>
+//	fabric_torrent keys [flags] recover <owner>
+//
+// list prints the records of the key store, export and import move them through an
+// encrypted backup and recover imports the keys of owner's files escrowed for the user.
+func keysCommand(args []string) error {
+	network := fabricclient.Flags()
+	keys := keyDBFlags()
+	backup := backupFlags()
+	recoveryKey := flag.String("recovery-msp-key", "", "private key (or keystore directory) of the recovery identity, to unwrap escrowed keys")
+	flag.CommandLine.Parse(args)
+	if flag.NArg() == 0 {
+		return errors.New(keysUsage)
+	}
+	op, operands := flag.Arg(0), flag.Args()[1:]
+	if (op == "list") != (len(operands) == 0) || len(operands) > 1 {
+		return errors.New(keysUsage)
+	}
+	if err := keys.unlock(); err != nil {
+		return err
+	}
+	defer keyStore.Close()
+
+	switch op {
+	case "list":
+		return listKeys()
+	case "export":
+		return backup.export(operands[0])
+	case "import":
+		return backup.importFile(operands[0])
+	case "recover":
+		priv, err := loadMSPKey(*recoveryKey)
+		if err != nil {
+			return err
+		}
+		chClient, _, err := connect(network)
+		if err != nil {
+			return err
+		}
+		recovered, err := recoverEscrow(chClient, operands[0], priv)
+		fmt.Println("recovered", recovered, "escrowed keys of", operands[0])
+		return err
+	}
+	return errors.New(keysUsage)
+}
+
+// listKeys prints every key record by name, without the keys
+func listKeys() error {
+	records, err := allRecords()
+	if err != nil {
+		return err
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].Version < records[j].Version
+	})
+	for _, r := range records {
+		fmt.Printf("%-30s v%-3d %-12s %s  %s  %s\n", r.Name, r.Version, r.Algorithm, r.ID, r.InfoHash, r.Created.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+// Package keystore keeps the keys of the files and directories fabric_torrent encrypts,
+// sealed under a master key or by a PKCS#11 token.
+package keystore
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// KeyStore holds the keys of the files and directories encrypted by this node
+type KeyStore interface {
+	Put(name string, key []byte) error
+	Get(name string) ([]byte, error)
+	Delete(name string) error
+	// List returns the names having a key, sorted
+	List() ([]string, error)
+	Close() error
+}
+
+// ErrNoKey is returned by Get for a name without a key
+var ErrNoKey = errors.New("no such key")
+
+// Config says where the keys are kept and how they are sealed
+type Config struct {
+	// Backend is leveldb, file (a JSON document) or pkcs11, which keeps the sealed
+	// keys in leveldb
+	Backend string
+	// Path is the leveldb directory or the JSON file, key.db or keys.json by default
+	Path   string
+	PKCS11 PKCS11Config
+}
+
+// Open opens the key store of config. secret is what the master key is derived from,
+// or the PIN of the PKCS#11 token. The first Open of a store sets the secret, and seals
+// the keys written before encryption at rest.
+func Open(config Config, secret []byte) (KeyStore, error) {
+	var b backend
+	var err error
+	switch config.Backend {
+	case "leveldb", "pkcs11":
+		path := config.Path
+		if path == "" {
+			path = "key.db"
+		}
+		b, err = openLevelDB(path)
+	case "file":
+		path := config.Path
+		if path == "" {
+			path = "keys.json"
+		}
+		b, err = openFileBackend(path)
+	default:
+		return nil, errors.Errorf("unknown key store %s", config.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s sealer
+	if config.Backend == "pkcs11" {
+		s, err = openPKCS11(config.PKCS11, string(secret))
+	} else {
+		s, err = masterSealerFor(b, secret)
+	}
+	if err != nil {
+		b.close()
+		return nil, err
+	}
+
+	store := &sealedStore{backend: b, sealer: s}
+	if err := sealRawKeys(store); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// backend keeps opaque values by name. Names starting with "\x00" are internal.
+type backend interface {
+	get(name string) ([]byte, error)
+	put(name string, value []byte) error
+	delete(name string) error
+	names() ([]string, error)
+	close() error
+}
+
+// sealer protects the keys written to a backend
+type sealer interface {
+	seal(name string, key []byte) ([]byte, error)
+	open(name string, sealed []byte) ([]byte, error)
+}
+
+// sealedStore is a KeyStore made of where the keys are kept and how they are protected
+type sealedStore struct {
+	backend
+	sealer
+}
+
+func (s *sealedStore) Put(name string, key []byte) error {
+	sealed, err := s.seal(name, key)
+	if err != nil {
+		return err
+	}
+	return s.put(name, append([]byte(sealedMarker), sealed...))
+}
+
+func (s *sealedStore) Get(name string) ([]byte, error) {
+	value, err := s.get(name)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(value, []byte(sealedMarker)) {
+		return nil, errors.Errorf("key of %s is not sealed", name)
+	}
+	key, err := s.open(name, value[len(sealedMarker):])
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open key of %s", name)
+	}
+	return key, nil
+}
+
+func (s *sealedStore) Delete(name string) error {
+	return s.delete(name)
+}
+
+func (s *sealedStore) List() ([]string, error) {
+	all, err := s.names()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range all {
+		if !strings.HasPrefix(name, "\x00") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *sealedStore) Close() error {
+	if c, ok := s.sealer.(io.Closer); ok {
+		c.Close()
+	}
+	return s.close()
+}
+
+// levelDBBackend keeps one leveldb open instead of opening key.db for every lookup
+type levelDBBackend struct {
+	db *leveldb.DB
+}
+
+func openLevelDB(path string) (*levelDBBackend, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &levelDBBackend{db: db}, nil
+}
+
+func (b *levelDBBackend) get(name string) ([]byte, error) {
+	value, err := b.db.Get([]byte(name), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrNoKey
+	}
+	return value, err
+}
+
+func (b *levelDBBackend) put(name string, value []byte) error {
+	return b.db.Put([]byte(name), value, nil)
+}
+
+func (b *levelDBBackend) delete(name string) error {
+	return b.db.Delete([]byte(name), nil)
+}
+
+func (b *levelDBBackend) names() ([]string, error) {
+	var names []string
+	iter := b.db.NewIterator(nil, nil)
+	for iter.Next() {
+		names = append(names, string(iter.Key()))
+	}
+	iter.Release()
+	return names, iter.Error()
+}
+
+func (b *levelDBBackend) close() error {
+	return b.db.Close()
+}
+
+// fileBackend keeps every value in one JSON document, which is easy to inspect in tests
+type fileBackend struct {
+	mu     sync.Mutex
+	path   string
+	values map[string][]byte
+}
+
+func openFileBackend(path string) (*fileBackend, error) {
+	b := &fileBackend{path: path, values: make(map[string][]byte)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &b.values); err != nil {
+		return nil, errors.Wrapf(err, "corrupt key store %s", path)
+	}
+	return b, nil
+}
+
+// save replaces the file, so a crash leaves either the old or the new content
+func (b *fileBackend) save() error {
+	data, err := json.MarshalIndent(b.values, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(b.path), ".keys")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), b.path)
+}
+
+func (b *fileBackend) get(name string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	value, ok := b.values[name]
+	if !ok {
+		return nil, ErrNoKey
+	}
+	return value, nil
+}
+
+func (b *fileBackend) put(name string, value []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.values[name] = value
+	return b.save()
+}
+
+func (b *fileBackend) delete(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.values, name)
+	return b.save()
+}
+
+func (b *fileBackend) names() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := make([]string, 0, len(b.values))
+	for name := range b.values {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (b *fileBackend) close() error {
+	return nil
+}
@@ -0,0 +1,280 @@
+package keystore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestFileBackend(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "keys.json")
+
+	b, err := openFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.get("a"); err != ErrNoKey {
+		t.Fatalf("get of a missing value: %v, expected ErrNoKey", err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if err := b.put(name, []byte("value of "+name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.delete("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := openFileBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		value string
+		err   error
+	}{
+		{"a", "value of a", nil},
+		{"b", "", ErrNoKey},
+		{"c", "value of c", nil},
+	}
+	for _, test := range tests {
+		value, err := reopened.get(test.name)
+		if err != test.err || string(value) != test.value {
+			t.Errorf("%s: %q, %v, expected %q, %v", test.name, value, err, test.value, test.err)
+		}
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, ".keys*")); len(files) != 0 {
+		t.Errorf("temporary files left: %v", files)
+	}
+}
+
+func TestFileBackendCorrupt(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "keys.json")
+	if err := ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openFileBackend(path); err == nil {
+		t.Fatal("opened a corrupt key store")
+	}
+}
+
+func TestSealUnseal(t *testing.T) {
+	master := bytes.Repeat([]byte{7}, 32)
+	other := bytes.Repeat([]byte{8}, 32)
+	sealed, err := Seal(master, "file/a", []byte("key of a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		master []byte
+		entry  string
+		sealed []byte
+		ok     bool
+	}{
+		{"round trip", master, "file/a", sealed, true},
+		{"other master key", other, "file/a", sealed, false},
+		{"other entry", master, "file/b", sealed, false},
+		{"tampered", master, "file/a", append(append([]byte{}, sealed[:len(sealed)-1]...), sealed[len(sealed)-1]^1), false},
+		{"truncated", master, "file/a", sealed[:5], false},
+		{"unknown version", master, "file/a", append([]byte{sealedVersion + 1}, sealed[1:]...), false},
+	}
+	for _, test := range tests {
+		key, err := Unseal(test.master, test.entry, test.sealed)
+		if test.ok && (err != nil || string(key) != "key of a") {
+			t.Errorf("%s: %q, %v", test.name, key, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%s: unsealed %q", test.name, key)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, backend := range []string{"file", "leveldb"} {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		config := Config{Backend: backend, Path: filepath.Join(dir, "keys")}
+
+		store, err := Open(config, []byte("passphrase"))
+		if err != nil {
+			t.Fatalf("%s: %s", backend, err)
+		}
+		for _, name := range []string{"name/b", "file/a"} {
+			if err := store.Put(name, []byte("key of "+name)); err != nil {
+				t.Fatalf("%s: %s", backend, err)
+			}
+		}
+		if err := store.Close(); err != nil {
+			t.Fatalf("%s: %s", backend, err)
+		}
+
+		if _, err := Open(config, []byte("wrong")); err == nil {
+			t.Fatalf("%s: opened with a wrong passphrase", backend)
+		}
+		store, err = Open(config, []byte("passphrase"))
+		if err != nil {
+			t.Fatalf("%s: %s", backend, err)
+		}
+		names, err := store.List()
+		if err != nil {
+			t.Fatalf("%s: %s", backend, err)
+		}
+		if expected := []string{"file/a", "name/b"}; !reflect.DeepEqual(names, expected) {
+			t.Errorf("%s: list %q, expected %q", backend, names, expected)
+		}
+		for _, name := range names {
+			key, err := store.Get(name)
+			if err != nil || string(key) != "key of "+name {
+				t.Errorf("%s: get %s: %q, %v", backend, name, key, err)
+			}
+		}
+		if _, err := store.Get("file/missing"); err != ErrNoKey {
+			t.Errorf("%s: get of a missing key: %v, expected ErrNoKey", backend, err)
+		}
+		store.Close()
+	}
+}
+
+func TestOpenKeepsKeysSealed(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	config := Config{Backend: "file", Path: filepath.Join(dir, "keys.json")}
+	key := bytes.Repeat([]byte("k"), 32)
+
+	store, err := Open(config, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("file/a", key); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	b, err := openFileBackend(config.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := b.get("file/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, key) {
+		t.Fatal("the key is stored in the clear")
+	}
+	// a sealed key moved to another entry does not open
+	if err := b.put("file/b", sealed); err != nil {
+		t.Fatal(err)
+	}
+	store, err = Open(config, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.Get("file/b"); err == nil {
+		t.Error("opened a sealed key under another entry")
+	}
+}
+
+func TestOpenSealsRawKeys(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	config := Config{Backend: "file", Path: filepath.Join(dir, "keys.json")}
+	key := bytes.Repeat([]byte("k"), 32)
+
+	b, err := openFileBackend(config.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.put("legacy", key); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := Open(config, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	got, err := store.Get("legacy")
+	if err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("get of a sealed raw key: %q, %v", got, err)
+	}
+	if b, err = openFileBackend(config.Path); err != nil {
+		t.Fatal(err)
+	}
+	if sealed, _ := b.get("legacy"); bytes.Equal(sealed, key) {
+		t.Error("the raw key was not sealed")
+	}
+}
+
+func TestOpenMarksSealedValues(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	config := Config{Backend: "file", Path: filepath.Join(dir, "keys.json")}
+
+	store, err := Open(config, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("file/a", []byte("key of a")); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// a value sealed before the marker, and raw keys of any length
+	b, err := openFileBackend(config.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marked, err := b.get("file/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unmarked := marked[len(sealedMarker):]
+	values := map[string][]byte{
+		"file/a":       unmarked,
+		"short":        []byte("16 byte long key"),
+		"legacy":       bytes.Repeat([]byte("k"), 32),
+		"looks sealed": append([]byte{sealedVersion}, bytes.Repeat([]byte("k"), 60)...),
+	}
+	for name, value := range values {
+		if err := b.put(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err = Open(config, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	values["file/a"] = []byte("key of a")
+	for name, value := range values {
+		got, err := store.Get(name)
+		if err != nil || !bytes.Equal(got, value) {
+			t.Errorf("%s: %q, %v, expected %q", name, got, err, value)
+		}
+	}
+	if b, err = openFileBackend(config.Path); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := b.get("file/a"); !bytes.Equal(value, append([]byte(sealedMarker), unmarked...)) {
+		t.Error("a sealed value was sealed again")
+	}
+}
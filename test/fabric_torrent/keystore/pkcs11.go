@@ -0,0 +1,156 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+// PKCS11Config selects the token and the AES key in it that wraps the file keys.
+// The key never leaves the token, file keys are sent to it to be wrapped and unwrapped.
+type PKCS11Config struct {
+	Library  string
+	Token    string
+	KeyLabel string
+}
+
+// pkcs11Sealer wraps file keys with AES-CBC in the token:
+//
+//	sealed = sealedVersion (1 byte) | iv (16 bytes) | encrypted sha256(name) | key
+type pkcs11Sealer struct {
+	mu      sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	kek     pkcs11.ObjectHandle
+}
+
+func openPKCS11(config PKCS11Config, pin string) (*pkcs11Sealer, error) {
+	ctx := pkcs11.New(config.Library)
+	if ctx == nil {
+		return nil, errors.Errorf("unable to load PKCS#11 module %s", config.Library)
+	}
+	if err := ctx.Initialize(); err != nil {
+		return nil, errors.Wrap(err, "PKCS#11 initialize")
+	}
+	slot, err := findSlot(ctx, config.Token)
+	if err != nil {
+		ctx.Finalize()
+		return nil, err
+	}
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		ctx.Finalize()
+		return nil, errors.Wrap(err, "PKCS#11 open session")
+	}
+	if err := ctx.Login(session, pkcs11.CKU_USER, pin); err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		ctx.CloseSession(session)
+		ctx.Finalize()
+		return nil, errors.Wrap(err, "PKCS#11 login")
+	}
+	kek, err := findOrCreateKEK(ctx, session, config.KeyLabel)
+	if err != nil {
+		ctx.CloseSession(session)
+		ctx.Finalize()
+		return nil, err
+	}
+	return &pkcs11Sealer{ctx: ctx, session: session, kek: kek}, nil
+}
+
+func findSlot(ctx *pkcs11.Ctx, label string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, errors.Wrap(err, "PKCS#11 slot list")
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err == nil && info.Label == label {
+			return slot, nil
+		}
+	}
+	return 0, errors.Errorf("no PKCS#11 token labelled %s", label)
+}
+
+// findOrCreateKEK returns the wrapping key of label, generating it in the token the first time
+func findOrCreateKEK(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, label string) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		return 0, errors.Wrap(err, "PKCS#11 find")
+	}
+	objects, _, err := ctx.FindObjects(session, 1)
+	ctx.FindObjectsFinal(session)
+	if err != nil {
+		return 0, errors.Wrap(err, "PKCS#11 find")
+	}
+	if len(objects) > 0 {
+		return objects[0], nil
+	}
+
+	template = append(template,
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 32),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+		pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+	)
+	kek, err := ctx.GenerateKey(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_KEY_GEN, nil)}, template)
+	if err != nil {
+		return 0, errors.Wrap(err, "PKCS#11 generate key")
+	}
+	return kek, nil
+}
+
+func (p *pkcs11Sealer) seal(name string, key []byte) ([]byte, error) {
+	iv := make([]byte, 16)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(name))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.ctx.EncryptInit(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_CBC_PAD, iv)}, p.kek); err != nil {
+		return nil, errors.Wrap(err, "PKCS#11 encrypt")
+	}
+	encrypted, err := p.ctx.Encrypt(p.session, append(sum[:], key...))
+	if err != nil {
+		return nil, errors.Wrap(err, "PKCS#11 encrypt")
+	}
+	return append(append([]byte{sealedVersion}, iv...), encrypted...), nil
+}
+
+func (p *pkcs11Sealer) open(name string, sealed []byte) ([]byte, error) {
+	if len(sealed) < 1+16 || sealed[0] != sealedVersion {
+		return nil, errors.Errorf("key of %s is not sealed", name)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.ctx.DecryptInit(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_CBC_PAD, sealed[1:17])}, p.kek); err != nil {
+		return nil, errors.Wrap(err, "PKCS#11 decrypt")
+	}
+	plain, err := p.ctx.Decrypt(p.session, sealed[17:])
+	if err != nil {
+		return nil, errors.Wrap(err, "PKCS#11 decrypt")
+	}
+	sum := sha256.Sum256([]byte(name))
+	if len(plain) < len(sum) || !bytes.Equal(plain[:len(sum)], sum[:]) {
+		return nil, errors.Errorf("sealed key does not belong to %s", name)
+	}
+	return plain[len(sum):], nil
+}
+
+func (p *pkcs11Sealer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ctx.Logout(p.session)
+	p.ctx.CloseSession(p.session)
+	return p.ctx.Finalize()
+}
@@ -0,0 +1,160 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// The values of key.db are sealed with AES-GCM under a master key, the name of the
+// entry is authenticated with it so sealed keys cannot be swapped between entries.
+// sealedStore prefixes every sealed value with sealedMarker:
+//
+//	value  = sealedMarker | sealed
+//	sealed = sealedVersion (1 byte) | nonce (12 bytes) | encrypted key | tag (16 bytes)
+//
+// The master key is derived with scrypt from a passphrase or from the private key
+// of the node's MSP. Its parameters and a check value live under masterEntry.
+
+const (
+	sealedVersion = 1
+	masterEntry   = "\x00master"
+	masterCheck   = "key.db"
+	// sealedMarker tells sealed values from the keys earlier versions stored in the
+	// clear, and from the values sealed before the marker existed
+	sealedMarker = "\x00sealed\x00"
+)
+
+// MasterParams are the scrypt parameters of a master key and a value sealed under it to
+// check it, stored under masterEntry
+type MasterParams struct {
+	Salt  []byte `json:"salt"`
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	Check []byte `json:"check"`
+}
+
+// masterSealer seals keys with AES-GCM under a master key derived with scrypt
+type masterSealer struct {
+	master []byte
+}
+
+func masterSealerFor(b backend, secret []byte) (*masterSealer, error) {
+	var params MasterParams
+	stored, err := b.get(masterEntry)
+	switch err {
+	case nil:
+		if err := json.Unmarshal(stored, &params); err != nil {
+			return nil, errors.Wrap(err, "corrupt key.db master entry")
+		}
+	case ErrNoKey:
+		params = MasterParams{Salt: make([]byte, 16), N: 1 << 15, R: 8, P: 1}
+		if _, err := rand.Read(params.Salt); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	master, err := scrypt.Key(secret, params.Salt, params.N, params.R, params.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	if params.Check != nil {
+		if _, err := Unseal(master, masterCheck, params.Check); err != nil {
+			return nil, errors.New("wrong key.db passphrase")
+		}
+	} else {
+		if params.Check, err = Seal(master, masterCheck, []byte(masterCheck)); err != nil {
+			return nil, err
+		}
+		data, _ := json.Marshal(params)
+		if err := b.put(masterEntry, data); err != nil {
+			return nil, err
+		}
+	}
+	return &masterSealer{master: master}, nil
+}
+
+func (m *masterSealer) seal(name string, key []byte) ([]byte, error) {
+	return Seal(m.master, name, key)
+}
+
+func (m *masterSealer) open(name string, sealed []byte) ([]byte, error) {
+	return Unseal(m.master, name, sealed)
+}
+
+// sealRawKeys marks the values sealed before sealedMarker existed and seals the keys
+// stored in the clear by earlier versions. A value without the marker is sealed when the
+// sealer opens it, which authenticates it, and raw otherwise.
+func sealRawKeys(store *sealedStore) error {
+	names, err := store.List()
+	if err != nil {
+		return err
+	}
+	sealed := 0
+	for _, name := range names {
+		value, err := store.get(name)
+		if err != nil {
+			return err
+		}
+		if bytes.HasPrefix(value, []byte(sealedMarker)) {
+			continue
+		}
+		if _, err := store.open(name, value); err == nil {
+			err = store.put(name, append([]byte(sealedMarker), value...))
+		} else {
+			err = store.Put(name, value)
+			sealed++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if sealed > 0 {
+		fmt.Println("sealed", sealed, "keys of the key store")
+	}
+	return nil
+}
+
+// Seal encrypts key with AES-GCM under master, authenticating name with it
+func Seal(master []byte, name string, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(master)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 1+gcm.NonceSize())
+	out[0] = sealedVersion
+	if _, err := rand.Read(out[1:]); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(out, out[1:], key, []byte(name)), nil
+}
+
+// Unseal returns the key Seal sealed for name under master
+func Unseal(master []byte, name string, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(master)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < 1+gcm.NonceSize() || sealed[0] != sealedVersion {
+		return nil, errors.Errorf("key of %s is not sealed", name)
+	}
+	nonce := sealed[1 : 1+gcm.NonceSize()]
+	return gcm.Open(nil, nonce, sealed[1+gcm.NonceSize():], []byte(name))
+}
@@ -0,0 +1,103 @@
+// Package keywrap wraps file keys for the public key of the identity they are sent to.
+//
+// The secrets exchanged through the keyExchange chaincode end up in events and on the
+// ledger, so a file key is only ever sent wrapped for the public key of its requester:
+// an ephemeral P-256 key agreement, sha256 of the shared secret as key of AES-GCM.
+//
+//	wrapped = ephemeral public key (65 bytes) | nonce (12 bytes) | sealed file key
+package keywrap
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+)
+
+const pointLength = 65
+
+func wrapKEK(shared []byte, ephemeral []byte) []byte {
+	kek := sha256.Sum256(append(shared, ephemeral...))
+	return kek[:]
+}
+
+// sharedSecret returns the x coordinate of priv*pub padded to the size of the curve
+func sharedSecret(curve elliptic.Curve, x, y *big.Int, d []byte) []byte {
+	sx, _ := curve.ScalarMult(x, y, d)
+	shared := make([]byte, (curve.Params().BitSize+7)/8)
+	b := sx.Bytes()
+	copy(shared[len(shared)-len(b):], b)
+	return shared
+}
+
+func gcmFor(kek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Wrap encrypts key so that only the owner of pub can read it
+func Wrap(pub *ecdsa.PublicKey, key []byte) ([]byte, error) {
+	eph, err := ecdsa.GenerateKey(pub.Curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	ephemeral := elliptic.Marshal(pub.Curve, eph.X, eph.Y)
+	gcm, err := gcmFor(wrapKEK(sharedSecret(pub.Curve, pub.X, pub.Y, eph.D.Bytes()), ephemeral))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out := append(ephemeral, nonce...)
+	return gcm.Seal(out, nonce, key, ephemeral), nil
+}
+
+// Unwrap reverses Wrap with the private key of the recipient
+func Unwrap(priv *ecdsa.PrivateKey, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < pointLength {
+		return nil, errors.New("wrapped key too short")
+	}
+	ephemeral := wrapped[:pointLength]
+	x, y := elliptic.Unmarshal(priv.Curve, ephemeral)
+	if x == nil {
+		return nil, errors.New("invalid ephemeral key")
+	}
+	gcm, err := gcmFor(wrapKEK(sharedSecret(priv.Curve, x, y, priv.D.Bytes()), ephemeral))
+	if err != nil {
+		return nil, err
+	}
+	rest := wrapped[pointLength:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errors.New("wrapped key too short")
+	}
+	return gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], ephemeral)
+}
+
+// EncodePublicKey is the form of a public key passed to requestSecret
+func EncodePublicKey(pub *ecdsa.PublicKey) string {
+	return hex.EncodeToString(elliptic.Marshal(pub.Curve, pub.X, pub.Y))
+}
+
+// DecodePublicKey reverses EncodePublicKey
+func DecodePublicKey(s string) (*ecdsa.PublicKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	x, y := elliptic.Unmarshal(elliptic.P256(), b)
+	if x == nil {
+		return nil, errors.New("invalid public key")
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
@@ -0,0 +1,61 @@
+package keywrap
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+)
+
+func TestWrapUnwrap(t *testing.T) {
+	recipient, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := bytes.Repeat([]byte{3}, 32)
+	pub, err := DecodePublicKey(EncodePublicKey(&recipient.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := Wrap(pub, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]byte{}, wrapped...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name    string
+		priv    *ecdsa.PrivateKey
+		wrapped []byte
+		ok      bool
+	}{
+		{"recipient", recipient, wrapped, true},
+		{"other key", other, wrapped, false},
+		{"tampered", recipient, tampered, false},
+		{"no nonce", recipient, wrapped[:pointLength+4], false},
+		{"no ephemeral key", recipient, wrapped[:10], false},
+	}
+	for _, test := range tests {
+		got, err := Unwrap(test.priv, test.wrapped)
+		if test.ok && (err != nil || !bytes.Equal(got, key)) {
+			t.Errorf("%s: %x, %v", test.name, got, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%s: unwrapped %x", test.name, got)
+		}
+	}
+}
+
+func TestDecodePublicKey(t *testing.T) {
+	for _, s := range []string{"", "zz", "04", "0400"} {
+		if _, err := DecodePublicKey(s); err == nil {
+			t.Errorf("decoded %q", s)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// fabric_torrent shares encrypted files over BitTorrent. Files are registered on a
+// Fabric channel, and their keys handed to the users allowed to request them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+)
+
+const (
+	origindataPath  = "origindata"
+	encryptdataPath = "encryptdata"
+	decryptdataPath = "decryptdata"
+	// respondersPath holds the certificates of share holders named in metadata, out of
+	// origindataPath so they are not published
+	respondersPath = "responders"
+)
+
+// Quotas
+var downloadSlots torrentSlots
+var budget *diskBudget
+
+var downloads *downloadManager
+
+// command is a subcommand, it parses its own flags from args
+type command struct {
+	run     func(args []string) error
+	summary string
+}
+
+var commands = map[string]command{
+	"bootstrap": {bootstrapCommand, "set up the channels and chaincodes of the network manifest, skipping what is done"},
+	"serve":     {serveCommand, "publish origindata, seed it and answer the requests for its keys"},
+	"publish":   {publishCommand, "publish a file or directory through the running daemon, or seed it until interrupted"},
+	"list":      {listCommand, "print every file registered on the ledger"},
+	"search":    {searchCommand, "print the files matching the flags"},
+	"request":   {requestCommand, "request the key of a file from its owner and print it"},
+	"fetch":     {fetchCommand, "download the subscribed files and those given by id, seed them and serve the console"},
+	"decrypt":   {decryptCommand, "decrypt a downloaded file or directory with its key"},
+	"keys":      {keysCommand, "export, import, recover or list the keys of the key store"},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags] [args]\n\ncommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> -h for the flags of a command.\n", os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	// commands register their flags on the default set, named after the command
+	flag.CommandLine.Init(os.Args[0]+" "+os.Args[1], flag.ExitOnError)
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// connect sets up the SDK for network and returns the channel client of its user and
+// the owner name the chaincodes record for it
+func connect(network *fabricclient.Config) (chclient.ChannelClient, string, error) {
+	c, err := fabricclient.New(*network)
+	if err != nil {
+		return nil, "", err
+	}
+	chClient, err := c.ChannelClient()
+	if err != nil {
+		return nil, "", err
+	}
+	owner, err := c.Owner()
+	if err != nil {
+		return nil, "", err
+	}
+	return chClient, owner, nil
+}
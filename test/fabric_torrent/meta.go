@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultKeyword is the keyword part of the composite key of files published without tags
+const defaultKeyword = "keywords"
+
+// metaSuffix names the sidecar of an entry of origindataPath, report.pdf.meta.yaml for
+// report.pdf. The sidecar is not published itself.
+const metaSuffix = ".meta.yaml"
+
+// metaDefaults applies to every entry of origindataPath without a value of its own
+const metaDefaults = ".meta.yaml"
+
+// fileMeta is what is registered along with a published entry:
+//
+//	tags: [reports, finance]
+//	summary: Quarterly report
+//	access: ["@org2.example.com", User1@org3.example.com]
+//	responders: [org2-responder.pem, org3-responder.pem]
+//	threshold: 2
+//
+// Tags form the keyword of the file, access lists who may request its key (everyone if
+// empty), responders are the certificates of the share holders of its key, relative to
+// respondersPath.
+type fileMeta struct {
+	Tags       []string `yaml:"tags,omitempty"`
+	Summary    string   `yaml:"summary,omitempty"`
+	Access     []string `yaml:"access,omitempty"`
+	Responders []string `yaml:"responders,omitempty"`
+	Threshold  int      `yaml:"threshold,omitempty"`
+}
+
+func readMeta(path string, meta *fileMeta) error {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(content, meta); err != nil {
+		return errors.Wrapf(err, "invalid metadata in %s", path)
+	}
+	return nil
+}
+
+// loadMeta returns the metadata of the entry name of origindataPath, its sidecar
+// overriding the defaults field by field
+func loadMeta(name string) (fileMeta, error) {
+	var defaults, meta fileMeta
+	if err := readMeta(filepath.Join(origindataPath, metaDefaults), &defaults); err != nil {
+		return meta, err
+	}
+	if err := readMeta(filepath.Join(origindataPath, name+metaSuffix), &meta); err != nil {
+		return meta, err
+	}
+	if meta.Tags == nil {
+		meta.Tags = defaults.Tags
+	}
+	if meta.Summary == "" {
+		meta.Summary = defaults.Summary
+	}
+	if meta.Access == nil {
+		meta.Access = defaults.Access
+	}
+	if meta.Responders == nil {
+		meta.Responders = defaults.Responders
+	}
+	if meta.Threshold == 0 {
+		meta.Threshold = defaults.Threshold
+	}
+	responders := make([]string, len(meta.Responders))
+	for i, path := range meta.Responders {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(respondersPath, path)
+		}
+		responders[i] = path
+	}
+	meta.Responders = responders
+	return meta, nil
+}
+
+// keyword returns the tags joined as they are stored in the keyword of the file
+func (m fileMeta) keyword() string {
+	var tags []string
+	for _, tag := range m.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return defaultKeyword
+	}
+	return strings.Join(tags, ",")
+}
+
+func (m fileMeta) access() string {
+	return strings.Join(m.Access, ",")
+}
+
+// isSidecar reports whether name of origindataPath holds metadata rather than content
+func isSidecar(name string) bool {
+	return strings.HasSuffix(name, metaSuffix)
+}
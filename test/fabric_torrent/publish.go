@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/cryptofile"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/seeding"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// controlSocket is where a running daemon takes the requests of the publish command
+var controlSocket = filepath.Join(encryptdataPath, ".control.sock")
+
+// syncMu serialises syncing entries, the watcher and publish requests may both sync one
+var syncMu sync.Mutex
+
+type publishRequest struct {
+	Name string `json:"name"`
+}
+
+type publishResult struct {
+	Name    string `json:"name"`
+	Outcome string `json:"outcome"`
+	ID      string `json:"id"`
+	Magnet  string `json:"magnet"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (r publishResult) String() string {
+	return fmt.Sprintf("%s %s\nid %s\nmagnet %s", r.Outcome, r.Name, r.ID, r.Magnet)
+}
+
+// publishName publishes the entry name of origindataPath now, or updates it
+func publishName(chClient chclient.ChannelClient, client *torrent.Client, owner, name string) (publishResult, error) {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+	result := publishResult{Name: name}
+	published, err := ledgerFiles(chClient, owner)
+	if err != nil {
+		return result, err
+	}
+	if result.Outcome, err = syncEntry(chClient, client, published, name); err != nil {
+		return result, err
+	}
+	if published, err = ledgerFiles(chClient, owner); err != nil {
+		return result, err
+	}
+	result.Magnet = published[name].Magnet
+	if m, err := metainfo.ParseMagnetURI(result.Magnet); err == nil {
+		result.ID = m.InfoHash.HexString()
+	}
+	return result, nil
+}
+
+// serveControl answers the publish command on controlSocket
+func serveControl(chClient chclient.ChannelClient, client *torrent.Client, owner string) error {
+	os.Remove(controlSocket)
+	l, err := net.Listen("unix", controlSocket)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(controlSocket, 0600); err != nil {
+		return err
+	}
+	return http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req publishRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+			http.Error(w, "expecting a name", http.StatusBadRequest)
+			return
+		}
+		result, err := publishName(chClient, client, owner, req.Name)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+}
+
+// publishWithDaemon hands name to the daemon listening on controlSocket, ok is false
+// when none is running
+func publishWithDaemon(name string) (result publishResult, ok bool, err error) {
+	httpClient := http.Client{Transport: &http.Transport{
+		Dial: func(_, _ string) (net.Conn, error) {
+			return net.Dial("unix", controlSocket)
+		},
+	}}
+	body, _ := json.Marshal(publishRequest{Name: name})
+	response, err := httpClient.Post("http://daemon/publish", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return result, false, nil
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return result, true, errors.Errorf("the daemon refused to publish %s: %s", name, response.Status)
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return result, true, err
+	}
+	if result.Error != "" {
+		return result, true, errors.New(result.Error)
+	}
+	return result, true, nil
+}
+
+// placeEntry puts path into origindataPath as name, hard linked where possible, so
+// that the published entry stays in sync with origindata like any other
+func placeEntry(path, name string) error {
+	dest := filepath.Join(origindataPath, name)
+	src, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(dest); err == nil {
+		if os.SameFile(src, fi) {
+			return nil
+		}
+		return errors.Errorf("%s already exists, remove it or publish under another -name", dest)
+	}
+	if !src.IsDir() {
+		return linkOrCopy(path, dest)
+	}
+	return cryptofile.Walk(path, func(rel string) error {
+		return linkOrCopy(filepath.Join(path, rel), filepath.Join(dest, rel))
+	})
+}
+
+func linkOrCopy(src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if os.Link(src, dest) == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// publishCommand implements
+//
+//	fabric_torrent publish [flags] <path>
+//
+// It places path in origindataPath with a sidecar holding the metadata flags, then has
+// the running daemon publish it, or publishes and seeds it itself until interrupted.
+func publishCommand(args []string) error {
+	network := fabricclient.Flags()
+	keys := keyDBFlags()
+	name := flag.String("name", "", "name to publish under, the base name of the path by default")
+	tags := flag.String("tags", "", "comma separated tags")
+	summary := flag.String("summary", "", "summary of the file")
+	access := flag.String("access", "", "comma separated users (User1@org2.example.com) or orgs (@org2.example.com) allowed to request the key")
+	responders := flag.String("responders", "", "comma separated certificates of the share holders of the key")
+	threshold := flag.Int("threshold", 0, "shares needed to recover the key split among -responders")
+	flag.Int64Var(&pieceLength, "piece-length", 0, "torrent piece length in bytes, 0 chooses it from the file size")
+	flag.StringVar(&webSeedURL, "webseed-url", "", "public url of the web seed added to the torrent")
+	flag.CommandLine.Parse(args)
+	if flag.NArg() != 1 {
+		return errors.New("usage: publish [flags] <path>")
+	}
+	path := flag.Arg(0)
+	if *name == "" {
+		*name = filepath.Base(path)
+	}
+	if strings.HasPrefix(*name, ".") || strings.ContainsRune(*name, filepath.Separator) || isSidecar(*name) {
+		return errors.Errorf("%s cannot be published as a top-level entry of %s", *name, origindataPath)
+	}
+
+	meta := fileMeta{Tags: splitList(*tags), Summary: *summary, Access: splitList(*access), Threshold: *threshold}
+	for _, cert := range splitList(*responders) {
+		abs, err := filepath.Abs(cert)
+		if err != nil {
+			return err
+		}
+		meta.Responders = append(meta.Responders, abs)
+	}
+	if err := placeEntry(path, *name); err != nil {
+		return err
+	}
+	content, err := yaml.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if string(content) != "{}\n" {
+		if err := ioutil.WriteFile(filepath.Join(origindataPath, *name+metaSuffix), content, 0644); err != nil {
+			return err
+		}
+	}
+
+	result, ok, err := publishWithDaemon(*name)
+	if ok {
+		if err == nil {
+			fmt.Println(result)
+		}
+		return err
+	}
+
+	// no daemon is running, publish and seed
+	if err := keys.unlock(); err != nil {
+		return err
+	}
+	defer keyStore.Close()
+	if webSeedURL != "" && !strings.HasSuffix(webSeedURL, "/") {
+		webSeedURL += "/"
+	}
+	chClient, owner, err := connect(network)
+	if err != nil {
+		return err
+	}
+	clientConfig := seeding.NewConfig(encryptdataPath, seeding.ListenAddr, nil)
+	client, err := torrent.NewClient(&clientConfig)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if result, err = publishName(chClient, client, owner, *name); err != nil {
+		return err
+	}
+	fmt.Println(result)
+	fmt.Println("seeding", *name, "until interrupted, run serve to seed it for good")
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+	return nil
+}
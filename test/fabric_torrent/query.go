@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"path"
+
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/cryptofile"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/pkg/errors"
+)
+
+// ledgerCatalog returns the catalog of every file registered on the ledger
+func ledgerCatalog(chClient chclient.ChannelClient) (*fileCatalog, error) {
+	files := newFileCatalog()
+	if _, err := files.refresh(chClient); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// listCommand implements
+//
+//	fabric_torrent list [flags]
+func listCommand(args []string) error {
+	network := fabricclient.Flags()
+	flag.CommandLine.Parse(args)
+	chClient, _, err := connect(network)
+	if err != nil {
+		return err
+	}
+	files, err := ledgerCatalog(chClient)
+	if err != nil {
+		return err
+	}
+	files.list()
+	return nil
+}
+
+// searchCommand implements
+//
+//	fabric_torrent search [flags]
+//
+// Every flag given has to match, like the rules of the subscription file.
+func searchCommand(args []string) error {
+	network := fabricclient.Flags()
+	var rule subscriptionRule
+	flag.StringVar(&rule.Owner, "owner", "", "owner of the file, e.g. User1@org2.example.com")
+	flag.StringVar(&rule.Org, "org-of-owner", "", "org of the owner, e.g. org2")
+	flag.StringVar(&rule.Tag, "tag", "", "tag of the file")
+	flag.StringVar(&rule.Name, "name", "", "glob the name of the file matches, e.g. *.pdf")
+	flag.Int64Var(&rule.MaxSize, "max-size", 0, "largest size in bytes, 0 for any")
+	flag.CommandLine.Parse(args)
+	if _, err := path.Match(rule.Name, ""); err != nil {
+		return errors.Wrapf(err, "invalid name pattern %q", rule.Name)
+	}
+	chClient, _, err := connect(network)
+	if err != nil {
+		return err
+	}
+	files, err := ledgerCatalog(chClient)
+	if err != nil {
+		return err
+	}
+	var matches []catalog.File
+	for _, file := range files.snapshot() {
+		if rule.match(file) {
+			matches = append(matches, file)
+		}
+	}
+	printFiles(matches)
+	return nil
+}
+
+// requestCommand implements
+//
+//	fabric_torrent request [flags] <id>
+//
+// It requests the key of the file with id, as printed by list, and prints it for decrypt.
+func requestCommand(args []string) error {
+	network := fabricclient.Flags()
+	flag.DurationVar(&secretTimeout, "timeout", secretTimeout, "how long to wait for the owner to answer")
+	flag.CommandLine.Parse(args)
+	if flag.NArg() != 1 {
+		return errors.New("usage: request [flags] <id>")
+	}
+	chClient, _, err := connect(network)
+	if err != nil {
+		return err
+	}
+	files, err := ledgerCatalog(chClient)
+	if err != nil {
+		return err
+	}
+	file, ok := files.get(flag.Arg(0))
+	if !ok {
+		return errors.Errorf("unknown file id %s", flag.Arg(0))
+	}
+	exchange, err := newKeyExchange(chClient)
+	if err != nil {
+		return err
+	}
+	key, err := exchange.fileKey(file)
+	if err != nil {
+		return err
+	}
+	cipherName := file.Cipher
+	if cipherName == "" {
+		cipherName = cryptofile.OFB
+	}
+	fmt.Println("key", hex.EncodeToString(key))
+	fmt.Println("cipher", cipherName)
+	return nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"golang.org/x/time/rate"
+)
+
+// quotaConfig limits what a node may use of a shared host. Zero means unlimited.
+type quotaConfig struct {
+	UploadRate   int   // bytes per second
+	DownloadRate int   // bytes per second
+	MaxTorrents  int   // torrents downloading at the same time
+	MaxPeers     int   // established connections per torrent
+	DiskBudget   int64 // bytes of ciphertext kept in encryptdataPath
+}
+
+// quotaFlags registers the quota command line flags, call before flag.Parse
+func quotaFlags() *quotaConfig {
+	q := &quotaConfig{}
+	flag.IntVar(&q.UploadRate, "upload-rate", 0, "upload limit in bytes per second")
+	flag.IntVar(&q.DownloadRate, "download-rate", 0, "download limit in bytes per second")
+	flag.IntVar(&q.MaxTorrents, "max-torrents", 0, "torrents downloading concurrently")
+	flag.IntVar(&q.MaxPeers, "max-peers", 0, "connections per torrent")
+	flag.Int64Var(&q.DiskBudget, "disk-budget", 0, "bytes of downloaded ciphertext kept on disk, least recently requested files are evicted first")
+	return q
+}
+
+func limiter(bytesPerSecond int) *rate.Limiter {
+	// the burst has to hold at least one chunk or the client stalls
+	burst := bytesPerSecond
+	if burst < 16*1024 {
+		burst = 16 * 1024
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), burst)
+}
+
+// apply sets the rate and connection limits on a torrent client configuration
+func (q *quotaConfig) apply(cfg *torrent.Config) {
+	if q.UploadRate > 0 {
+		cfg.UploadRateLimiter = limiter(q.UploadRate)
+	}
+	if q.DownloadRate > 0 {
+		cfg.DownloadRateLimiter = limiter(q.DownloadRate)
+	}
+	if q.MaxPeers > 0 {
+		cfg.EstablishedConnsPerTorrent = q.MaxPeers
+		cfg.HalfOpenConnsPerTorrent = (q.MaxPeers + 1) / 2
+	}
+}
+
+// torrentSlots bounds the number of torrents downloading at the same time
+type torrentSlots chan struct{}
+
+func newTorrentSlots(n int) torrentSlots {
+	if n <= 0 {
+		return nil
+	}
+	return make(torrentSlots, n)
+}
+
+func (s torrentSlots) acquire() {
+	if s != nil {
+		s <- struct{}{}
+	}
+}
+
+func (s torrentSlots) release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// diskBudget keeps the ciphertext under encryptdataPath below a size limit by
+// evicting the entries that were requested least recently. A directory is one entry,
+// its size is that of its whole tree. The entries this node published have a key record
+// and hold the only copy of their ciphertext, they are neither counted nor evicted.
+type diskBudget struct {
+	mu        sync.Mutex
+	limit     int64
+	dir       string
+	stateFile string
+	client    *torrent.Client
+	requested map[string]time.Time
+}
+
+func newDiskBudget(limit int64, dir string, client *torrent.Client) *diskBudget {
+	b := &diskBudget{
+		limit:     limit,
+		dir:       dir,
+		stateFile: filepath.Join(dir, ".requested.json"),
+		client:    client,
+		requested: make(map[string]time.Time),
+	}
+	if content, err := ioutil.ReadFile(b.stateFile); err == nil {
+		json.Unmarshal(content, &b.requested)
+	}
+	return b
+}
+
+// touch records that name was requested now
+func (b *diskBudget) touch(name string) {
+	if b == nil || b.limit <= 0 || name == "" {
+		return
+	}
+	b.mu.Lock()
+	b.requested[name] = time.Now()
+	b.save()
+	b.mu.Unlock()
+}
+
+func (b *diskBudget) save() {
+	content, _ := json.Marshal(b.requested)
+	if err := ioutil.WriteFile(b.stateFile, content, 0600); err != nil {
+		fmt.Println("unable to save request times: ", err)
+	}
+}
+
+// busy reports whether the torrent serving name is still downloading
+func (b *diskBudget) busy(name string) bool {
+	for _, t := range b.client.Torrents() {
+		if t.Name() != name {
+			continue
+		}
+		select {
+		case <-t.GotInfo():
+			return t.BytesCompleted() < t.Info().TotalLength()
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+func (b *diskBudget) drop(name string) {
+	for _, t := range b.client.Torrents() {
+		if t.Name() == name {
+			t.Drop()
+		}
+	}
+}
+
+// enforce evicts ciphertext until the budget is respected
+func (b *diskBudget) enforce() {
+	if b == nil || b.limit <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	fi, err := ioutil.ReadDir(b.dir)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var used int64
+	var entries []os.FileInfo
+	sizes := make(map[string]int64)
+	for _, x := range fi {
+		if strings.HasPrefix(x.Name(), ".") || published(x.Name()) {
+			continue
+		}
+		size, err := treeSize(filepath.Join(b.dir, x.Name()))
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		used += size
+		sizes[x.Name()] = size
+		entries = append(entries, x)
+	}
+	// entries that were never requested sort first
+	sort.Slice(entries, func(i, j int) bool {
+		return b.requested[entries[i].Name()].Before(b.requested[entries[j].Name()])
+	})
+	for _, x := range entries {
+		if used <= b.limit {
+			break
+		}
+		if b.busy(x.Name()) {
+			continue
+		}
+		b.drop(x.Name())
+		if err := os.RemoveAll(filepath.Join(b.dir, x.Name())); err != nil {
+			fmt.Println("unable to evict", x.Name(), err)
+			continue
+		}
+		fmt.Println("evicted", x.Name())
+		used -= sizes[x.Name()]
+		delete(b.requested, x.Name())
+	}
+	b.save()
+}
+
+// published reports whether this node published the entry name, by its key record
+func published(name string) bool {
+	if keyStore == nil {
+		return false
+	}
+	_, err := recordByName(name)
+	return err == nil
+}
+
+// treeSize returns the size of the file at path, or of every file under it
+func treeSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// run enforces the budget periodically
+func (b *diskBudget) run(interval time.Duration) {
+	if b == nil || b.limit <= 0 {
+		return
+	}
+	for {
+		b.enforce()
+		time.Sleep(interval)
+	}
+}
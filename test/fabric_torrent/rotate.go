@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/pkg/errors"
+)
+
+// rotatedPath keeps the ciphertext of previous versions that are still seeded
+var rotatedPath = filepath.Join(encryptdataPath, ".rotated")
+
+// rotatedInfo is the bencoded info of a previous version, stored next to its ciphertext
+const rotatedInfo = ".info"
+
+// rotateFile re-encrypts a published file under a new key, seeds the new ciphertext and
+// publishes it as the next version of the file. Requests still waiting for the old key
+// are revoked. With keepOld the previous torrent goes on seeding from rotatedPath,
+// otherwise it is dropped. When the ledger refuses the new version the previous one is
+// restored, see updateFile.
+func rotateFile(chClient chclient.ChannelClient, client *torrent.Client, owner, name string, keepOld bool) error {
+	published, err := ledgerFiles(chClient, owner)
+	if err != nil {
+		return err
+	}
+	old, ok := published[name]
+	if !ok {
+		return errors.Errorf("%s is not published", name)
+	}
+	hash, err := plaintextHash(name)
+	if err != nil {
+		return err
+	}
+	meta, err := loadMeta(name)
+	if err != nil {
+		return err
+	}
+	oldDir, oldInfo, err := updateFile(chClient, client, old, hash, meta)
+	if err != nil {
+		return err
+	}
+	if _, err := fabricclient.NewExchange(chClient).Revoke(context.Background(), old.Key()); err != nil {
+		fmt.Println("unable to revoke requests for", name, err)
+	}
+
+	if !keepOld {
+		return os.RemoveAll(oldDir)
+	}
+	if oldInfo == nil {
+		return errors.Errorf("the info of the previous version of %s is unknown, it is not seeded", name)
+	}
+	if err := ioutil.WriteFile(filepath.Join(oldDir, rotatedInfo), oldInfo, 0600); err != nil {
+		return err
+	}
+	return seedRotated(client, oldDir)
+}
+
+// seedRotated seeds a previous version kept by rotateFile
+func seedRotated(client *torrent.Client, dir string) error {
+	infoBytes, err := ioutil.ReadFile(filepath.Join(dir, rotatedInfo))
+	if err != nil {
+		return err
+	}
+	spec := &torrent.TorrentSpec{
+		InfoHash:  metainfo.HashBytes(infoBytes),
+		InfoBytes: infoBytes,
+		Storage:   storage.NewFile(dir),
+	}
+	_, _, err = client.AddTorrentSpec(spec)
+	return err
+}
+
+// seedAllRotated seeds again the previous versions kept before a restart
+func seedAllRotated(client *torrent.Client) {
+	dirs, err := ioutil.ReadDir(rotatedPath)
+	if err != nil {
+		return
+	}
+	for _, d := range dirs {
+		if err := seedRotated(client, filepath.Join(rotatedPath, d.Name())); err != nil {
+			fmt.Println("unable to seed previous version", d.Name(), err)
+		}
+	}
+}
+
+// purgeRotated stops seeding and deletes the previous versions of name kept by rotateFile
+func purgeRotated(client *torrent.Client, name string) error {
+	dirs, err := ioutil.ReadDir(rotatedPath)
+	if err != nil {
+		return nil
+	}
+	for _, d := range dirs {
+		dir := filepath.Join(rotatedPath, d.Name())
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			continue
+		}
+		if infoBytes, err := ioutil.ReadFile(filepath.Join(dir, rotatedInfo)); err == nil {
+			if t, ok := client.Torrent(metainfo.HashBytes(infoBytes)); ok {
+				t.Drop()
+			}
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
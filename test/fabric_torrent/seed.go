@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/keystore"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/seeding"
+	"github.com/pkg/errors"
+)
+
+// maxInfoBytes bounds the bencoded info dictionary stored on the ledger next to the magnet,
+// so clients can add a torrent before any peer answers. 0 only stores the magnet and size.
+var maxInfoBytes = 256 * 1024
+
+// seedSummary records what reconcileOrigindata did with every local file
+type seedSummary struct {
+	Published []string
+	Skipped   []string
+	Updated   []string
+	Failed    []string
+}
+
+func (s seedSummary) String() string {
+	return fmt.Sprintf("published %d %v, skipped %d %v, updated %d %v, failed %d %v",
+		len(s.Published), s.Published, len(s.Skipped), s.Skipped,
+		len(s.Updated), s.Updated, len(s.Failed), s.Failed)
+}
+
+// ledgerFiles returns the files registered by owner, indexed by name
+func ledgerFiles(chClient chclient.ChannelClient, owner string) (map[string]catalog.File, error) {
+	records, err := fabricclient.NewCatalog(chClient).Query(context.Background(), fabricclient.Filter{Owner: owner})
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]catalog.File)
+	for _, file := range records {
+		if file.Owner == owner {
+			files[file.Name] = file
+		}
+	}
+	return files, nil
+}
+
+// haveLocalCopy reports whether the key and the ciphertext of filename are still available,
+// which is required to seed it again without re-encrypting
+func haveLocalCopy(filename string) bool {
+	if _, err := os.Stat(filepath.Join(encryptdataPath, filename)); err != nil {
+		return false
+	}
+	_, err := loadKey(filename)
+	return err == nil
+}
+
+// seedsAs seeds the existing ciphertext of the published file old and reports whether it
+// still holds the plaintext of hash under the torrent of old.Magnet. The key record tells
+// which plaintext and torrent the ciphertext was made for, so the torrent is seeded as
+// published whatever -piece-length is now. Keys migrated from a name keyed key.db have no
+// infohash, their torrent is built again and dropped when it is not the published one.
+func seedsAs(client *torrent.Client, old catalog.File, hash string) bool {
+	r, err := recordByName(old.Name)
+	if err != nil || r.ID != hash {
+		return false
+	}
+	spec, err := torrent.TorrentSpecFromMagnetURI(old.Magnet)
+	if err != nil {
+		return false
+	}
+	if r.InfoHash != "" {
+		if !strings.EqualFold(r.InfoHash, spec.InfoHash.HexString()) {
+			return false
+		}
+		spec.InfoBytes = old.Info
+		spec.Storage = storage.NewFile(encryptdataPath)
+		_, _, err := client.AddTorrentSpec(spec)
+		return err == nil
+	}
+	a, err := seeding.MakeMagnet(client, encryptdataPath, old.Name, pieceLength, webSeedURL)
+	if err != nil {
+		return false
+	}
+	m, err := metainfo.ParseMagnetURI(a)
+	if err != nil {
+		return false
+	}
+	if m.InfoHash != spec.InfoHash {
+		if t, ok := client.Torrent(m.InfoHash); ok {
+			t.Drop()
+		}
+		return false
+	}
+	return true
+}
+
+// ledgerInfo returns the info dictionary of the torrent behind magnet if it may go on the ledger,
+// otherwise nil
+func ledgerInfo(client *torrent.Client, magnet string) []byte {
+	m, err := metainfo.ParseMagnetURI(magnet)
+	if err != nil {
+		return nil
+	}
+	t, ok := client.Torrent(m.InfoHash)
+	if !ok {
+		return nil
+	}
+	info := t.Metainfo().InfoBytes
+	if len(info) == 0 || len(info) > maxInfoBytes {
+		return nil
+	}
+	return info
+}
+
+func encryptedSize(name string) int64 {
+	size, err := seeding.TotalLength(filepath.Join(encryptdataPath, name))
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// publishFile encrypts filename, seeds it and registers it on the ledger with meta
+func publishFile(chClient chclient.ChannelClient, client *torrent.Client, filename, hash string, meta fileMeta) error {
+	key, err := encryptEntry(filename)
+	if err != nil {
+		return err
+	}
+	d, err := seeding.MakeMagnet(client, encryptdataPath, filename, pieceLength, webSeedURL)
+	if err != nil {
+		return err
+	}
+	fmt.Println(d)
+	if err := recordMagnet(filename, d); err != nil {
+		fmt.Println("unable to record infohash of", filename, err)
+	}
+	spec := fabricclient.FileSpec{Name: filename, Hash: hash, Keyword: meta.keyword(), Summary: meta.Summary, Magnet: d,
+		Size: encryptedSize(filename), Info: ledgerInfo(client, d), Cipher: fileCipher, Access: meta.access()}
+	file, err := fabricclient.NewCatalog(chClient).CreateFile(context.Background(), spec)
+	if err != nil {
+		return errors.Wrap(err, "Failed to add a magnetlink")
+	}
+	fmt.Println("username : ", file.Owner)
+	escrowHexKey(chClient, file, key)
+	depositHexShares(chClient, file, key, meta)
+	return nil
+}
+
+// updateFile re-encrypts a file whose content changed since it was registered and
+// publishes the new version. The previous ciphertext is moved aside to oldDir under
+// rotatedPath until the ledger has the new version, oldInfo is the info of its torrent.
+// When the ledger refuses the update the previous ciphertext, key and torrent are restored.
+func updateFile(chClient chclient.ChannelClient, client *torrent.Client, old catalog.File, hash string, meta fileMeta) (oldDir string, oldInfo []byte, err error) {
+	spec, err := torrent.TorrentSpecFromMagnetURI(old.Magnet)
+	if err != nil {
+		return "", nil, err
+	}
+	previous, err := recordByName(old.Name)
+	if err != nil && err != keystore.ErrNoKey {
+		return "", nil, err
+	}
+
+	// the new ciphertext is written where the old torrent reads from
+	oldInfo = old.Info
+	if t, ok := client.Torrent(spec.InfoHash); ok {
+		if t.Info() != nil {
+			oldInfo = t.Metainfo().InfoBytes
+		}
+		t.Drop()
+	}
+	oldDir = filepath.Join(rotatedPath, spec.InfoHash.HexString())
+	if err := os.MkdirAll(oldDir, 0700); err != nil {
+		return "", nil, err
+	}
+	path := filepath.Join(encryptdataPath, old.Name)
+	if err := os.Rename(path, filepath.Join(oldDir, old.Name)); err != nil && !os.IsNotExist(err) {
+		return "", nil, err
+	}
+
+	restore := func(err error) (string, []byte, error) {
+		if restoreErr := restorePrevious(client, old.Name, previous, oldDir, oldInfo); restoreErr != nil {
+			fmt.Println("unable to restore the previous version of", old.Name, restoreErr)
+		}
+		return "", nil, err
+	}
+	key, err := encryptEntry(old.Name)
+	if err != nil {
+		return restore(err)
+	}
+	d, err := seeding.MakeMagnet(client, encryptdataPath, old.Name, pieceLength, webSeedURL)
+	if err != nil {
+		return restore(err)
+	}
+	fmt.Println(d)
+	if err := recordMagnet(old.Name, d); err != nil {
+		fmt.Println("unable to record infohash of", old.Name, err)
+	}
+	update := fabricclient.FileSpec{Hash: hash, Magnet: d, Size: encryptedSize(old.Name), Info: ledgerInfo(client, d), Cipher: fileCipher}
+	if err := fabricclient.NewCatalog(chClient).UpdateFile(context.Background(), old.Key(), update); err != nil {
+		return restore(errors.Wrap(err, "Failed to update file"))
+	}
+	// the record of the previous version stays, its infohash still points at it
+	escrowHexKey(chClient, old.Key(), key)
+	depositHexShares(chClient, old.Key(), key, meta)
+	return oldDir, oldInfo, nil
+}
+
+// restorePrevious undoes an update the ledger refused: it drops the new torrent and key
+// record, puts the previous ciphertext of name back, points the name at the previous
+// record again, if there was one, and seeds the previous version again
+func restorePrevious(client *torrent.Client, name string, previous keyRecord, oldDir string, oldInfo []byte) error {
+	if current, err := recordByName(name); err == nil && current.ref() != previous.ref() {
+		if current.InfoHash != "" {
+			var h metainfo.Hash
+			if b, err := hex.DecodeString(current.InfoHash); err == nil && copy(h[:], b) == len(h) {
+				if t, ok := client.Torrent(h); ok {
+					t.Drop()
+				}
+			}
+			if err := keyStore.Delete(infoHashPrefix + current.InfoHash); err != nil && err != keystore.ErrNoKey {
+				return err
+			}
+		}
+		if err := keyStore.Delete(recordPrefix + current.ref()); err != nil && err != keystore.ErrNoKey {
+			return err
+		}
+	}
+	path := filepath.Join(encryptdataPath, name)
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(oldDir, name), path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(oldDir); err != nil {
+		return err
+	}
+	if previous.Name == "" {
+		if err := keyStore.Delete(namePrefix + name); err != nil && err != keystore.ErrNoKey {
+			return err
+		}
+		return nil
+	}
+	if err := putRecord(previous); err != nil {
+		return err
+	}
+	if oldInfo == nil {
+		return nil
+	}
+	_, _, err := client.AddTorrentSpec(&torrent.TorrentSpec{
+		InfoHash:  metainfo.HashBytes(oldInfo),
+		InfoBytes: oldInfo,
+		Storage:   storage.NewFile(encryptdataPath),
+	})
+	return err
+}
+
+// reconcileOrigindata compares every file and directory in origindataPath with the ledger by plaintext hash.
+// Unchanged files are only seeded again, changed files are re-encrypted and updated and
+// unknown files are published.
+func reconcileOrigindata(chClient chclient.ChannelClient, client *torrent.Client, owner string) (summary seedSummary, err error) {
+	published, err := ledgerFiles(chClient, owner)
+	if err != nil {
+		return summary, err
+	}
+
+	dir, err := os.Open(origindataPath)
+	if err != nil {
+		return summary, err
+	}
+	defer dir.Close()
+
+	fi, err := dir.Readdir(-1)
+	if err != nil {
+		return summary, err
+	}
+	for _, x := range fi {
+		if x.Name() == ".torrent.bolt.db" || strings.HasPrefix(x.Name(), ".") || isSidecar(x.Name()) {
+			continue
+		}
+		name := x.Name()
+		outcome, err := syncEntry(chClient, client, published, name)
+		switch {
+		case err != nil:
+			fmt.Println(name, err)
+			summary.Failed = append(summary.Failed, name)
+		case outcome == "published":
+			summary.Published = append(summary.Published, name)
+		case outcome == "updated":
+			summary.Updated = append(summary.Updated, name)
+		default:
+			summary.Skipped = append(summary.Skipped, name)
+		}
+	}
+	return summary, nil
+}
+
+// syncEntry brings the ledger, published by name, up to date with the entry name of
+// origindataPath and its metadata, and reports whether it was "published", "updated" or "skipped"
+func syncEntry(chClient chclient.ChannelClient, client *torrent.Client, published map[string]catalog.File, name string) (string, error) {
+	hash, err := plaintextHash(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to hash %s", name)
+	}
+	meta, err := loadMeta(name)
+	if err != nil {
+		return "", err
+	}
+	old, ok := published[name]
+	if !ok {
+		return "published", publishFile(chClient, client, name, hash, meta)
+	}
+	if old.Keyword != meta.keyword() {
+		fmt.Println("the tags of", name, "are part of its key on the ledger, they stay", old.Keyword)
+	}
+	outcome := "skipped"
+	if old.Hash == hash && haveLocalCopy(name) && seedsAs(client, old, hash) {
+		// keys migrated from a name keyed key.db learn their infohash here
+		recordMagnet(name, old.Magnet)
+	} else {
+		oldDir, _, err := updateFile(chClient, client, old, hash, meta)
+		if err != nil {
+			return "", err
+		}
+		if err := os.RemoveAll(oldDir); err != nil {
+			fmt.Println("unable to remove the previous version of", name, err)
+		}
+		outcome = "updated"
+	}
+	if old.Summary != meta.Summary || old.Access != meta.access() {
+		if err := fabricclient.NewCatalog(chClient).UpdateMeta(context.Background(), old.Key(), meta.Summary, meta.access()); err != nil {
+			return "", errors.Wrap(err, "Failed to update metadata")
+		}
+		outcome = "updated"
+	}
+	return outcome, nil
+}
+
+// ingestEntry is the ingest queue handler of the daemon
+func ingestEntry(chClient chclient.ChannelClient, client *torrent.Client, owner string) func(job ingestJob) error {
+	return func(job ingestJob) error {
+		syncMu.Lock()
+		defer syncMu.Unlock()
+		published, err := ledgerFiles(chClient, owner)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(origindataPath, job.Name)); os.IsNotExist(err) {
+			if _, ok := published[job.Name]; !ok || job.Op != ingestRemove {
+				fmt.Println(job.Name, "is not in", origindataPath)
+				return nil
+			}
+			return unpublishEntry(chClient, client, published, job.Name, purgeDeleted)
+		}
+		outcome, err := syncEntry(chClient, client, published, job.Name)
+		if err != nil {
+			return err
+		}
+		fmt.Println(outcome, job.Name)
+		return nil
+	}
+}
+
+// purgeDeleted also deletes the ciphertext and keys of files unpublished because they
+// were removed from origindataPath
+var purgeDeleted bool
+
+// unpublishEntry unpublishes name, published by name, see unpublishFile. Purging also
+// deletes the previous versions kept by rotateFile.
+func unpublishEntry(chClient chclient.ChannelClient, client *torrent.Client, published map[string]catalog.File, name string, purge bool) error {
+	old, ok := published[name]
+	if !ok {
+		return errors.Errorf("%s is not published", name)
+	}
+	if err := unpublishFile(chClient, client, old, purge); err != nil {
+		return err
+	}
+	if purge {
+		return purgeRotated(client, name)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package seeding
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pkg/errors"
+)
+
+// ChoosePieceLength aims at about 1500 pieces with a power of two between 256 KiB and 16 MiB
+func ChoosePieceLength(totalLength int64) int64 {
+	length := int64(256 * 1024)
+	for length < 16*1024*1024 && totalLength/length > 1500 {
+		length *= 2
+	}
+	return length
+}
+
+// TotalLength returns the size of the file root, or of every file under the directory root
+func TotalLength(root string) (total int64, err error) {
+	err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			total += fi.Size()
+		}
+		return nil
+	})
+	return
+}
+
+// MakeMagnet builds the torrent of dir/name, which is either a single file or a directory
+// published as one multi-file torrent, adds it to cl for seeding and returns its magnet link.
+// pieceLength 0 chooses it from the size of the data, webSeed is added to the torrent and
+// the magnet unless empty.
+func MakeMagnet(cl *torrent.Client, dir, name string, pieceLength int64, webSeed string) (string, error) {
+	root := filepath.Join(dir, name)
+	length := pieceLength
+	if length <= 0 {
+		size, err := TotalLength(root)
+		if err != nil {
+			return "", err
+		}
+		length = ChoosePieceLength(size)
+	}
+	mi := metainfo.MetaInfo{}
+	mi.SetDefaults()
+	if webSeed != "" {
+		mi.UrlList = []string{webSeed}
+	}
+	info := metainfo.Info{PieceLength: length}
+	if err := info.BuildFromFilePath(root); err != nil {
+		return "", errors.Wrapf(err, "unable to build torrent of %s", root)
+	}
+	infoBytes, err := bencode.Marshal(info)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to encode torrent info")
+	}
+	mi.InfoBytes = infoBytes
+	if _, err := cl.AddTorrent(&mi); err != nil {
+		return "", errors.Wrapf(err, "unable to seed %s", root)
+	}
+	magnet := mi.Magnet(name, mi.HashInfoBytes()).String()
+	if webSeed != "" {
+		magnet += "&ws=" + url.QueryEscape(webSeed)
+	}
+	return magnet, nil
+}
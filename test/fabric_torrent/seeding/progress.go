@@ -0,0 +1,47 @@
+package seeding
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/dustin/go-humanize"
+	"github.com/gosuri/uiprogress"
+)
+
+// ProgressBar adds a bar following the download of t, shown once uiprogress is started
+func ProgressBar(t *torrent.Torrent) {
+	bar := uiprogress.AddBar(1)
+	bar.AppendCompleted()
+	bar.AppendFunc(func(*uiprogress.Bar) (ret string) {
+		select {
+		case <-t.GotInfo():
+		default:
+			return "getting info"
+		}
+		if t.Seeding() {
+			return "seeding"
+		} else if t.BytesCompleted() == t.Info().TotalLength() {
+			return "completed"
+		} else {
+			return fmt.Sprintf("downloading (%s/%s)", humanize.Bytes(uint64(t.BytesCompleted())), humanize.Bytes(uint64(t.Info().TotalLength())))
+		}
+	})
+	bar.PrependFunc(func(*uiprogress.Bar) string {
+		return t.Name()
+	})
+	go func() {
+		<-t.GotInfo()
+		tl := int(t.Info().TotalLength())
+		if tl == 0 {
+			bar.Set(1)
+			return
+		}
+		bar.Total = tl
+		for {
+			bc := t.BytesCompleted()
+			bar.Set(int(bc))
+			time.Sleep(time.Second)
+		}
+	}()
+}
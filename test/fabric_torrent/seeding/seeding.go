@@ -0,0 +1,47 @@
+// Package seeding creates the torrent clients fabric_torrent seeds and downloads with.
+package seeding
+
+import (
+	"net"
+
+	"github.com/anacrolix/dht"
+	"github.com/anacrolix/torrent"
+	"github.com/pkg/errors"
+)
+
+// ListenAddr is where nodes accept peers by default. The node started first is the
+// DHT bootstrap node the others find through the dht_server chaincode.
+const ListenAddr = "0.0.0.0:6666"
+
+// NewConfig returns the configuration of a client seeding everything it has in dataDir.
+// It joins the DHT through startingNodes, none for the bootstrap node.
+func NewConfig(dataDir, listenAddr string, startingNodes []string) torrent.Config {
+	clientConfig := torrent.Config{}
+	clientConfig.Seed = true
+	clientConfig.Debug = true
+	clientConfig.DisableTrackers = true
+	clientConfig.ListenAddr = listenAddr
+	clientConfig.DHTConfig = dht.ServerConfig{
+		StartingNodes: Addrs(startingNodes),
+	}
+	clientConfig.DataDir = dataDir
+	clientConfig.DisableAggressiveUpload = false
+	return clientConfig
+}
+
+// Addrs resolves the host:port addresses of nodes when the DHT starts
+func Addrs(nodes []string) func() ([]dht.Addr, error) {
+	return func() (addrs []dht.Addr, err error) {
+		for _, s := range nodes {
+			ua, err := net.ResolveUDPAddr("udp4", s)
+			if err != nil {
+				continue
+			}
+			addrs = append(addrs, dht.NewAddr(ua))
+		}
+		if len(addrs) == 0 {
+			err = errors.New("nothing resolved")
+		}
+		return
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/seeding"
+	"github.com/pkg/errors"
+)
+
+// serveCommand implements
+//
+//	fabric_torrent serve [flags]
+//
+// It publishes every entry of origindataPath and keeps the ledger in sync with it,
+// seeds the ciphertext and answers the requests for the keys. It is the DHT bootstrap
+// node the other nodes find through the dht_server chaincode.
+func serveCommand(args []string) error {
+	network := fabricclient.Flags()
+	quota := quotaFlags()
+	keys := keyDBFlags()
+	listen := flag.String("listen", seeding.ListenAddr, "address to accept peers on")
+	escrowCert := flag.String("escrow-cert", "", "certificate of the recovery identity the key of every published file is escrowed for")
+	holderCerts := flag.String("share-holders", "", "comma separated certificates of responders of other orgs the key of every published file is split among")
+	flag.IntVar(&shareThreshold, "share-threshold", 2, "shares needed to recover a key split among -share-holders")
+	holderKey := flag.String("share-msp-key", "", "private key (or keystore directory) of this node, to answer requests with the shares it holds for other owners")
+	flag.Int64Var(&pieceLength, "piece-length", 0, "torrent piece length in bytes, 0 chooses it from the file size")
+	flag.IntVar(&maxInfoBytes, "max-info-bytes", maxInfoBytes, "largest torrent info stored on the ledger, 0 disables")
+	webSeedListen := flag.String("webseed-listen", "", "address to serve encryptdata over HTTP on, e.g. :8080")
+	rotate := flag.String("rotate", "", "comma separated files to re-encrypt under a new key and publish as a new version")
+	rotateKeepOld := flag.Bool("rotate-keep-old", false, "keep seeding the previous version of rotated files")
+	unpublish := flag.String("unpublish", "", "comma separated files to remove from the ledger and stop seeding")
+	purge := flag.Bool("purge", false, "also delete ciphertext and keys of the files given to -unpublish")
+	flag.BoolVar(&purgeDeleted, "purge-deleted", false, "delete ciphertext and keys of files unpublished because they were removed from origindata")
+	flag.StringVar(&webSeedURL, "webseed-url", "", "public url of the web seed added to every torrent, e.g. http://server:8080/")
+	flag.CommandLine.Parse(args)
+	// the ciphertext of what this node publishes cannot be fetched again, only fetch
+	// evicts ciphertext
+	if quota.DiskBudget > 0 {
+		return errors.New("-disk-budget does not apply to serve, it would delete the only copy of the published ciphertext")
+	}
+	if err := keys.unlock(); err != nil {
+		return err
+	}
+	defer keyStore.Close()
+	if *holderCerts != "" {
+		var err error
+		if shareHolders, err = loadShareHolders(splitList(*holderCerts)); err != nil {
+			return err
+		}
+		if shareThreshold < 1 || shareThreshold > len(shareHolders) {
+			return errors.New("-share-threshold must be between 1 and the number of share holders")
+		}
+	}
+	if *holderKey != "" {
+		var err error
+		if shareKey, err = loadMSPKey(*holderKey); err != nil {
+			return err
+		}
+	}
+	if *escrowCert != "" {
+		var err error
+		escrowRecipient, escrowName, err = loadCertificateKey(*escrowCert)
+		if err != nil {
+			return err
+		}
+	}
+	if webSeedURL != "" && !strings.HasSuffix(webSeedURL, "/") {
+		webSeedURL += "/"
+	}
+
+	if *webSeedListen != "" {
+		go func() {
+			log.Fatalln(serveWebSeed(*webSeedListen))
+		}()
+	}
+	chClient, owner, err := connect(network)
+	if err != nil {
+		return err
+	}
+
+	clientConfig := seeding.NewConfig(encryptdataPath, *listen, nil)
+	quota.apply(&clientConfig)
+	client, err := torrent.NewClient(&clientConfig)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	summary, err := reconcileOrigindata(chClient, client, owner)
+	if err != nil {
+		fmt.Println("Failed to reconcile origindata: ", err)
+	}
+	fmt.Println("seeding summary: ", summary)
+	seedAllRotated(client)
+	for _, name := range splitList(*rotate) {
+		if err := rotateFile(chClient, client, owner, name, *rotateKeepOld); err != nil {
+			fmt.Println("Failed to rotate", name, err)
+		} else {
+			fmt.Println("rotated", name)
+		}
+	}
+	if *unpublish != "" {
+		published, err := ledgerFiles(chClient, owner)
+		if err != nil {
+			fmt.Println("Failed to unpublish: ", err)
+		}
+		for _, name := range splitList(*unpublish) {
+			if err := unpublishEntry(chClient, client, published, name, *purge); err != nil {
+				fmt.Println("Failed to unpublish", name, err)
+			} else if _, err := os.Stat(filepath.Join(origindataPath, name)); err == nil {
+				fmt.Println(name, "is still in", origindataPath, "and will be published again on restart")
+			}
+		}
+	}
+
+	go func() {
+		fmt.Println("control socket: ", serveControl(chClient, client, owner))
+	}()
+
+	//monitor origin data path
+	queue := newIngestQueue(filepath.Join(encryptdataPath, ingestStateFile), ingestEntry(chClient, client, owner))
+	if err := watchOrigindata(queue); err != nil {
+		return err
+	}
+
+	return answerRequests(chClient, owner)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/keywrap"
+	"github.com/pkg/errors"
+)
+
+// pieceLength is the torrent piece length in bytes, 0 chooses it from the size of the data
+var pieceLength int64
+
+// webSeedURL is the public address of serveWebSeed. It ends with a slash so clients
+// append the torrent name as BEP-19 describes.
+var webSeedURL string
+
+// serveWebSeed exposes the ciphertext in encryptdataPath over HTTP so clients that
+// reach no peer can fall back to range requests
+func serveWebSeed(addr string) error {
+	files := http.FileServer(http.Dir(encryptdataPath))
+	return http.ListenAndServe(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, part := range strings.Split(r.URL.Path, "/") {
+			// keep the bookkeeping files of the daemon private
+			if strings.HasPrefix(part, ".") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		files.ServeHTTP(w, r)
+	}))
+}
+
+// wrappedSecret returns the key of name wrapped for the requester's public key
+func wrappedSecret(name, pubKey string) (string, error) {
+	if pubKey == "" {
+		return "", errors.New("the request carries no public key to wrap the secret for")
+	}
+	pub, err := keywrap.DecodePublicKey(pubKey)
+	if err != nil {
+		return "", err
+	}
+	key, err := loadKey(name)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := keywrap.Wrap(pub, key)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(wrapped), nil
+}
+
+// answerRequests answers the requests for the keys of owner's files, and for the shares
+// this node holds of the keys of other owners, until the process exits
+func answerRequests(listener chclient.ChannelClient, owner string) error {
+	events, err := fabricclient.Events(listener, fabricclient.ExchangeCC, "requestSecret")
+	if err != nil {
+		return err
+	}
+	for ccEvent := range events {
+		fmt.Println("requestSecret happened")
+		message := catalog.RequestMessage{}
+		json.Unmarshal(ccEvent.Payload, &message)
+		fmt.Println(message)
+		if message.To != owner {
+			if shareKey != nil {
+				if err := respondShare(listener, message); err != nil {
+					fmt.Println("unable to answer request", message.TxID, err)
+				}
+			}
+			continue
+		}
+		file, ok := catalog.ParseFileKey(message.File)
+		if !ok {
+			fmt.Println("unable to answer request", message.TxID, "invalid file key")
+			continue
+		}
+		name := file.Name
+		budget.touch(name)
+		secret, err := wrappedSecret(name, message.PubKey)
+		if err != nil {
+			fmt.Println("unable to answer request", message.TxID, err)
+			continue
+		}
+		if err := fabricclient.NewExchange(listener).Respond(context.Background(), secret, message.TxID); err != nil {
+			fmt.Println("error in respond", err)
+		} else {
+			fmt.Println("respondSecret success")
+		}
+	}
+	return nil
+}
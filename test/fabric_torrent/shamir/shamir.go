@@ -0,0 +1,114 @@
+// Package shamir splits the keys of fabric_torrent among their share holders.
+//
+// Shamir secret sharing over GF(2^8), byte by byte: every byte of the secret is the
+// constant term of its own random polynomial of degree threshold-1, share i holds the
+// values of all polynomials at x = i.
+//
+//	share = x (1 byte) | one value per byte of the secret
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+var gfExp, gfLog [256]byte
+
+func init() {
+	// 3 generates the multiplicative group of GF(2^8) reduced by x^8+x^4+x^3+x+1
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfLog[x] = byte(i)
+		x = gfMulSlow(x, 3)
+	}
+	gfExp[255] = gfExp[0]
+}
+
+func gfMulSlow(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+int(gfLog[b]))%255]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])-int(gfLog[b])+255)%255]
+}
+
+// Split returns n shares of secret, any threshold of which recover it
+func Split(secret []byte, n, threshold int) ([][]byte, error) {
+	if threshold < 1 || threshold > n || n > 255 {
+		return nil, errors.New("invalid number of shares")
+	}
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+	coefficients := make([]byte, threshold)
+	for j, s := range secret {
+		coefficients[0] = s
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for _, share := range shares {
+			// Horner's rule
+			var y byte
+			for c := threshold - 1; c >= 0; c-- {
+				y = gfMul(y, share[0]) ^ coefficients[c]
+			}
+			share[j+1] = y
+		}
+	}
+	return shares, nil
+}
+
+// Combine recovers the secret from at least threshold distinct shares by Lagrange
+// interpolation at x = 0
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares")
+	}
+	length := len(shares[0])
+	seen := make(map[byte]bool)
+	for _, share := range shares {
+		if len(share) != length || length < 2 || share[0] == 0 || seen[share[0]] {
+			return nil, errors.New("invalid shares")
+		}
+		seen[share[0]] = true
+	}
+	secret := make([]byte, length-1)
+	for i, si := range shares {
+		// Lagrange basis polynomial of share i at 0
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = gfMul(basis, gfDiv(sj[0], sj[0]^si[0]))
+			}
+		}
+		for k := range secret {
+			secret[k] ^= gfMul(si[k+1], basis)
+		}
+	}
+	return secret, nil
+}
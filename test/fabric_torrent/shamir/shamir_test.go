@@ -0,0 +1,91 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestField(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			p := gfMul(byte(a), byte(b))
+			if p != gfMulSlow(byte(a), byte(b)) {
+				t.Fatalf("%d * %d = %d, expected %d", a, b, p, gfMulSlow(byte(a), byte(b)))
+			}
+			if q := gfDiv(p, byte(b)); q != byte(a) {
+				t.Fatalf("%d * %d / %d = %d", a, b, b, q)
+			}
+		}
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	tests := []struct {
+		name      string
+		n         int
+		threshold int
+		pick      []int
+		recovers  bool
+	}{
+		{"threshold of one", 3, 1, []int{2}, true},
+		{"every share", 3, 3, []int{0, 1, 2}, true},
+		{"threshold shares", 5, 3, []int{4, 0, 2}, true},
+		{"more than threshold", 5, 3, []int{0, 1, 2, 3}, true},
+		{"below threshold", 5, 3, []int{1, 3}, false},
+		{"most shares", 255, 2, []int{254, 100}, true},
+	}
+	for _, test := range tests {
+		shares, err := Split(secret, test.n, test.threshold)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if len(shares) != test.n {
+			t.Fatalf("%s: %d shares, expected %d", test.name, len(shares), test.n)
+		}
+		var picked [][]byte
+		for _, i := range test.pick {
+			picked = append(picked, shares[i])
+		}
+		got, err := Combine(picked)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if bytes.Equal(got, secret) != test.recovers {
+			t.Errorf("%s: recovered %x", test.name, got)
+		}
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	tests := []struct {
+		n, threshold int
+	}{
+		{3, 0},
+		{3, 4},
+		{256, 2},
+	}
+	for _, test := range tests {
+		if _, err := Split([]byte("secret"), test.n, test.threshold); err == nil {
+			t.Errorf("split in %d with threshold %d, expected an error", test.n, test.threshold)
+		}
+	}
+}
+
+func TestCombineInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		shares [][]byte
+	}{
+		{"none", nil},
+		{"zero x", [][]byte{{0, 1}, {1, 2}}},
+		{"same x", [][]byte{{1, 1}, {1, 2}}},
+		{"lengths differ", [][]byte{{1, 1}, {2, 2, 2}}},
+		{"empty value", [][]byte{{1}, {2}}},
+	}
+	for _, test := range tests {
+		if _, err := Combine(test.shares); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
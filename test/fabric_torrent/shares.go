@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/keywrap"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/shamir"
+	"github.com/pkg/errors"
+)
+
+// shareHolder is a responder of another organisation holding a share of file keys
+type shareHolder struct {
+	name string
+	pub  *ecdsa.PublicKey
+}
+
+// With shareHolders set the key of every published file is split so that any
+// shareThreshold of them can answer a request while the owner is offline.
+// shareKey is the private key of this node when it is a holder for others.
+var (
+	shareHolders   []shareHolder
+	shareThreshold int
+	shareKey       *ecdsa.PrivateKey
+)
+
+// loadShareHolders reads the certificates of the holders
+func loadShareHolders(certs []string) ([]shareHolder, error) {
+	var holders []shareHolder
+	for _, path := range certs {
+		pub, cn, err := loadCertificateKey(path)
+		if err != nil {
+			return nil, err
+		}
+		holders = append(holders, shareHolder{name: cn, pub: pub})
+	}
+	return holders, nil
+}
+
+// fileHolders returns the share holders of a file and the shares needed to recover its
+// key, the responders of its metadata if any, -share-holders otherwise
+func fileHolders(meta fileMeta) ([]shareHolder, int, error) {
+	if len(meta.Responders) == 0 {
+		return shareHolders, shareThreshold, nil
+	}
+	holders, err := loadShareHolders(meta.Responders)
+	if err != nil {
+		return nil, 0, err
+	}
+	threshold := meta.Threshold
+	if threshold == 0 {
+		threshold = shareThreshold
+	}
+	if threshold < 1 || threshold > len(holders) {
+		return nil, 0, errors.Errorf("threshold %d is not between 1 and %d responders", threshold, len(holders))
+	}
+	return holders, threshold, nil
+}
+
+// depositShares splits key among holders and stores the shares on the ledger, each
+// wrapped for its holder
+func depositShares(chClient chclient.ChannelClient, file catalog.FileKey, key []byte, holders []shareHolder, threshold int) error {
+	secrets, err := shamir.Split(key, len(holders), threshold)
+	if err != nil {
+		return err
+	}
+	var shares []fabricclient.Share
+	for i, holder := range holders {
+		wrapped, err := keywrap.Wrap(holder.pub, secrets[i])
+		if err != nil {
+			return err
+		}
+		shares = append(shares, fabricclient.Share{Holder: holder.name, Secret: hex.EncodeToString(wrapped)})
+	}
+	if _, err := fabricclient.NewExchange(chClient).DepositShares(context.Background(), file, threshold, shares); err != nil {
+		return errors.Wrap(err, "Failed to deposit shares")
+	}
+	return nil
+}
+
+// depositHexShares shares the hex encoded key returned by encryptEntry, if sharing is
+// enabled for the file
+func depositHexShares(chClient chclient.ChannelClient, file catalog.FileKey, hexKey string, meta fileMeta) {
+	holders, threshold, err := fileHolders(meta)
+	if err == nil && len(holders) == 0 {
+		return
+	}
+	var key []byte
+	if err == nil {
+		key, err = hex.DecodeString(hexKey)
+	}
+	if err == nil {
+		err = depositShares(chClient, file, key, holders, threshold)
+	}
+	if err != nil {
+		fmt.Println("unable to share key of", file.Name, err)
+	}
+}
+
+// respondShare answers a request for a file of another owner with the share this node
+// holds of its key, if any
+func respondShare(listener chclient.ChannelClient, message catalog.RequestMessage) error {
+	file, ok := catalog.ParseFileKey(message.File)
+	if !ok {
+		return errors.Errorf("invalid file key %q", message.File)
+	}
+	if message.PubKey == "" {
+		return errors.New("the request carries no public key to wrap the share for")
+	}
+	exchange := fabricclient.NewExchange(listener)
+	held, err := exchange.Share(context.Background(), file)
+	if fabricclient.Reason(err) == fabricclient.ErrNoShare {
+		// not a holder of this file
+		return nil
+	} else if err != nil {
+		return err
+	}
+	wrapped, err := hex.DecodeString(held)
+	if err != nil {
+		return err
+	}
+	share, err := keywrap.Unwrap(shareKey, wrapped)
+	if err != nil {
+		return errors.Wrap(err, "unable to unwrap share")
+	}
+	pub, err := keywrap.DecodePublicKey(message.PubKey)
+	if err != nil {
+		return err
+	}
+	rewrapped, err := keywrap.Wrap(pub, share)
+	if err != nil {
+		return err
+	}
+	if err := exchange.RespondShare(context.Background(), message.TxID, hex.EncodeToString(rewrapped)); err != nil {
+		return errors.Wrap(err, "error in respond share")
+	}
+	fmt.Println("respondShare success")
+	return nil
+}
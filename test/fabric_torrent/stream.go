@@ -0,0 +1,175 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/cryptofile"
+	"github.com/pkg/errors"
+)
+
+// readahead is how much of the torrent after the read position is prioritised
+const readahead = 4 * 1024 * 1024
+
+// torrentReader is the part of the reader returned by Torrent.NewReader used here
+type torrentReader interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// fileSection restricts a torrent reader to the bytes of one file of the torrent
+type fileSection struct {
+	r      torrentReader
+	begin  int64
+	length int64
+	pos    int64
+}
+
+func (s *fileSection) Read(p []byte) (int, error) {
+	if s.pos >= s.length {
+		return 0, io.EOF
+	}
+	if rest := s.length - s.pos; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	n, err := s.r.Read(p)
+	s.pos += int64(n)
+	if err == io.EOF && s.pos < s.length {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (s *fileSection) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.length
+	default:
+		return s.pos, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return s.pos, errors.New("negative position")
+	}
+	if _, err := s.r.Seek(s.begin+offset, io.SeekStart); err != nil {
+		return s.pos, err
+	}
+	s.pos = offset
+	return offset, nil
+}
+
+func (s *fileSection) Close() error {
+	return s.r.Close()
+}
+
+// decryptReader decrypts a ciphertext while it is read. Seeking repositions the
+// keystream, which is immediate for aes-256-ctr.
+type decryptReader struct {
+	r          io.ReadSeeker
+	closer     io.Closer
+	cipherName string
+	key, iv    []byte
+	stream     cipher.Stream
+}
+
+func newDecryptReader(r io.ReadSeeker, closer io.Closer, cipherName string, key, iv []byte) (*decryptReader, error) {
+	stream, err := cryptofile.NewStream(cipherName, key, iv, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: r, closer: closer, cipherName: cipherName, key: key, iv: iv, stream: stream}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.stream.XORKeyStream(p[:n], p[:n])
+	return n, err
+}
+
+func (d *decryptReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := d.r.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	d.stream, err = cryptofile.NewStream(d.cipherName, d.key, d.iv, pos)
+	return pos, err
+}
+
+func (d *decryptReader) Close() error {
+	return d.closer.Close()
+}
+
+// openPlaintext returns a reader of the plaintext of one file of t that can be used
+// while t is still downloading. Pieces under the read position are downloaded first.
+// path is the path of the file inside a multi-file torrent and empty otherwise.
+func openPlaintext(t *torrent.Torrent, cipherName string, key []byte, path string) (*decryptReader, int64, error) {
+	<-t.GotInfo()
+	info := t.Info()
+	r := t.NewReader()
+	r.SetReadahead(readahead)
+	r.SetResponsive()
+
+	if !info.IsDir() {
+		var iv [aes.BlockSize]byte
+		d, err := newDecryptReader(r, r, cipherName, key, iv[:])
+		return d, info.TotalLength(), err
+	}
+
+	var begin int64
+	for _, fi := range info.UpvertedFiles() {
+		rel := filepath.Join(fi.Path...)
+		if filepath.ToSlash(rel) == strings.TrimPrefix(filepath.ToSlash(path), "/") {
+			section := &fileSection{r: r, begin: begin, length: fi.Length}
+			if _, err := section.Seek(0, io.SeekStart); err != nil {
+				r.Close()
+				return nil, 0, err
+			}
+			d, err := newDecryptReader(section, section, cipherName, key, cryptofile.FileIV(rel))
+			return d, fi.Length, err
+		}
+		begin += fi.Length
+	}
+	r.Close()
+	return nil, 0, errors.Errorf("%s has no file %s", info.Name, path)
+}
+
+// streamToDisk writes the plaintext of a catalog entry to decryptdataPath as its pieces arrive
+func streamToDisk(client *torrent.Client, files *fileCatalog, id, hexKey, path string) error {
+	file, ok := files.get(id)
+	if !ok {
+		return errors.Errorf("unknown file id %s", id)
+	}
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return err
+	}
+	t, err := addTorrent(client, file)
+	if err != nil {
+		return err
+	}
+	r, _, err := openPlaintext(t, file.Cipher, key, path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	out := filepath.Join(decryptdataPath, file.Name, path)
+	if err := os.MkdirAll(filepath.Dir(out), 0700); err != nil {
+		return err
+	}
+	outFile, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+	_, err = io.Copy(outFile, r)
+	return err
+}
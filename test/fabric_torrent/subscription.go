@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// subscriptionRule selects catalog entries to fetch automatically.
+// Every non-empty field has to match; Name is a glob as understood by path.Match.
+type subscriptionRule struct {
+	Owner   string `yaml:"owner"`
+	Org     string `yaml:"org"`
+	Tag     string `yaml:"tag"`
+	Name    string `yaml:"name"`
+	MaxSize int64  `yaml:"maxSize"`
+}
+
+// subscriptions is the content of the subscription file, e.g.
+//
+//	rules:
+//	- org: org1
+//	  tag: reports
+//	  maxSize: 104857600
+//	- owner: User1@org2.example.com
+//	  name: "*.pdf"
+type subscriptions struct {
+	Rules []subscriptionRule `yaml:"rules"`
+	all   bool
+}
+
+// loadSubscriptions reads the rules from file. Without a subscription file every
+// catalog entry is fetched, which is what the client always did.
+func loadSubscriptions(file string) (*subscriptions, error) {
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return &subscriptions{all: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	subs := &subscriptions{}
+	if err := yaml.Unmarshal(content, subs); err != nil {
+		return nil, errors.Wrapf(err, "invalid subscription file %s", file)
+	}
+	for _, r := range subs.Rules {
+		if _, err := path.Match(r.Name, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid name pattern %q", r.Name)
+		}
+	}
+	return subs, nil
+}
+
+// ownerOrg returns the domain part of an owner name such as User1@org1.example.com
+func ownerOrg(owner string) string {
+	if i := strings.LastIndex(owner, "@"); i >= 0 {
+		return owner[i+1:]
+	}
+	return ""
+}
+
+func (r subscriptionRule) match(file catalog.File) bool {
+	if r.Owner != "" && r.Owner != file.Owner {
+		return false
+	}
+	if r.Org != "" {
+		org := strings.ToLower(ownerOrg(file.Owner))
+		want := strings.ToLower(r.Org)
+		if org != want && !strings.HasPrefix(org, want+".") {
+			return false
+		}
+	}
+	if r.Tag != "" {
+		found := false
+		for _, tag := range strings.Split(file.Keyword, ",") {
+			if strings.TrimSpace(tag) == r.Tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.Name != "" {
+		if ok, _ := path.Match(r.Name, file.Name); !ok {
+			return false
+		}
+	}
+	// files registered without a size never satisfy a size limit
+	if r.MaxSize > 0 && (file.Size <= 0 || file.Size > r.MaxSize) {
+		return false
+	}
+	return true
+}
+
+// wants reports whether file should be fetched without being asked for
+func (s *subscriptions) wants(file catalog.File) bool {
+	if s.all {
+		return true
+	}
+	for _, r := range s.Rules {
+		if r.match(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileID identifies a catalog entry by the infohash of its magnet
+func fileID(file catalog.File) string {
+	m, err := metainfo.ParseMagnetURI(file.Magnet)
+	if err != nil {
+		return ""
+	}
+	return m.InfoHash.HexString()
+}
+
+// fileCatalog is the client's view of the files registered on the ledger
+type fileCatalog struct {
+	mu    sync.Mutex
+	files map[string]catalog.File
+}
+
+func newFileCatalog() *fileCatalog {
+	return &fileCatalog{files: make(map[string]catalog.File)}
+}
+
+func (c *fileCatalog) add(file catalog.File) string {
+	id := fileID(file)
+	if id == "" {
+		return ""
+	}
+	c.mu.Lock()
+	c.files[id] = file
+	c.mu.Unlock()
+	return id
+}
+
+// remove forgets the entries of the File composite key ckey, sent with deleteFile events
+func (c *fileCatalog) remove(ckey string) {
+	key, ok := catalog.ParseFileKey(ckey)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, f := range c.files {
+		if f.Key() == key {
+			delete(c.files, id)
+		}
+	}
+}
+
+// replace puts file in place of the entries of the same file key, sent with updateFile
+// events, and returns the entries it replaced
+func (c *fileCatalog) replace(file catalog.File) []catalog.File {
+	id := fileID(file)
+	if id == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var replaced []catalog.File
+	for old, f := range c.files {
+		if f.Key() == file.Key() {
+			replaced = append(replaced, f)
+			delete(c.files, old)
+		}
+	}
+	c.files[id] = file
+	return replaced
+}
+
+func (c *fileCatalog) get(id string) (catalog.File, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	file, ok := c.files[strings.ToLower(id)]
+	return file, ok
+}
+
+// list prints every known catalog entry with the id to use for on-demand fetch
+func (c *fileCatalog) list() {
+	printFiles(c.snapshot())
+}
+
+// printFiles prints files by id, one per line
+func printFiles(files []catalog.File) {
+	sort.Slice(files, func(i, j int) bool { return fileID(files[i]) < fileID(files[j]) })
+	for _, f := range files {
+		fmt.Printf("%s  %-30s %-30s %d\n", fileID(f), f.Name, f.Owner, f.Size)
+	}
+}
+
+// snapshot returns every known catalog entry
+func (c *fileCatalog) snapshot() []catalog.File {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	files := make([]catalog.File, 0, len(c.files))
+	for _, f := range c.files {
+		files = append(files, f)
+	}
+	return files
+}
+
+// refresh loads every file record from the ledger
+func (c *fileCatalog) refresh(chClient chclient.ChannelClient) ([]catalog.File, error) {
+	records, err := fabricclient.NewCatalog(chClient).Query(context.Background(), fabricclient.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	files := make([]catalog.File, 0, len(records))
+	for _, file := range records {
+		if c.add(file) != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// fetch downloads a catalog entry that was not selected by the subscriptions
+func fetch(client *torrent.Client, files *fileCatalog, id string) {
+	file, ok := files.get(strings.TrimSpace(id))
+	if !ok {
+		fmt.Println("unknown file id", id)
+		return
+	}
+	fmt.Println("fetching", file.Name)
+	download(client, file)
+}
+
+// readCommands serves the on-demand commands typed on the console:
+//
+//	list        print the catalog
+//	fetch <id>  download a file by id
+//	status      print the state of every download
+//	decrypt <id> <hex key> [path]
+//	            write the plaintext to decryptdataPath while the file downloads
+//	unpublish [-purge] <name>
+//	            remove a file published by owner from the ledger and stop
+//	            seeding it, -purge also deletes its ciphertext and keys
+func readCommands(r io.Reader, chClient chclient.ChannelClient, client *torrent.Client, files *fileCatalog, owner string) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "list":
+			files.list()
+		case "status":
+			downloads.printStatus()
+		case "decrypt":
+			if len(fields) < 3 {
+				fmt.Println("usage: decrypt <id> <hex key> [path in torrent]")
+				continue
+			}
+			path := ""
+			if len(fields) > 3 {
+				path = fields[3]
+			}
+			go func(id, key, path string) {
+				if err := streamToDisk(client, files, id, key, path); err != nil {
+					fmt.Println(err)
+				}
+			}(fields[1], fields[2], path)
+		case "fetch":
+			for _, id := range fields[1:] {
+				fetch(client, files, id)
+			}
+		case "unpublish":
+			purge := len(fields) > 1 && fields[1] == "-purge"
+			if purge {
+				fields = fields[1:]
+			}
+			if len(fields) != 2 {
+				fmt.Println("usage: unpublish [-purge] <name>")
+				continue
+			}
+			published, err := ledgerFiles(chClient, owner)
+			if err == nil {
+				err = unpublishEntry(chClient, client, published, fields[1], purge)
+			}
+			if err != nil {
+				fmt.Println(err)
+			}
+		default:
+			fmt.Println("unknown command", fields[0])
+		}
+	}
+}
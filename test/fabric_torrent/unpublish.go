@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn/chclient"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/fabricclient"
+	"github.com/pkg/errors"
+)
+
+// unpublishFile removes file from the ledger, revokes the requests still waiting for its
+// key and stops seeding it. With purge its ciphertext and key records are deleted as
+// well, once every request for the key is answered or revoked.
+func unpublishFile(chClient chclient.ChannelClient, client *torrent.Client, file catalog.File, purge bool) error {
+	if err := fabricclient.NewCatalog(chClient).DeleteFile(context.Background(), file.Key()); err != nil {
+		return errors.Wrapf(err, "Failed to delete %s", file.Name)
+	}
+	// no request can be made for a file missing from the ledger, the revocation resolves
+	// every request left. Until it succeeds the key is kept to answer them.
+	if _, err := fabricclient.NewExchange(chClient).Revoke(context.Background(), file.Key()); err != nil {
+		purge = false
+		fmt.Println("unable to revoke requests for", file.Name, err, "- its key and ciphertext are kept")
+	}
+	if m, err := metainfo.ParseMagnetURI(file.Magnet); err == nil {
+		if t, ok := client.Torrent(m.InfoHash); ok {
+			t.Drop()
+		}
+	}
+	fmt.Println("unpublished", file.Name)
+	if !purge {
+		return nil
+	}
+	return purgeEntry(file.Name)
+}
+
+// purgeEntry deletes the ciphertext and every key of the entry name
+func purgeEntry(name string) error {
+	if err := os.RemoveAll(filepath.Join(encryptdataPath, name)); err != nil {
+		return err
+	}
+	if err := deleteRecords(name); err != nil {
+		return errors.Wrapf(err, "unable to delete the keys of %s", name)
+	}
+	fmt.Println("purged", name)
+	return nil
+}
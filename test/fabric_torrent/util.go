@@ -0,0 +1,38 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+
+	"github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/cryptofile"
+)
+
+// fileCipher is used for everything encrypted from now on
+const fileCipher = cryptofile.Default
+
+// encryptEntry encrypts a file or a directory of origindataPath to encryptdataPath under a
+// new key, which is stored in the key store, and returns the key
+func encryptEntry(name string) (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	if err := storeKey(name, fileCipher, key); err != nil {
+		return "", errors.New("unable to put key in db")
+	}
+	if err := cryptofile.CryptEntry(fileCipher, key, filepath.Join(origindataPath, name), filepath.Join(encryptdataPath, name)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// loadKey returns the key name of origindataPath was last encrypted with by this node
+func loadKey(name string) ([]byte, error) {
+	record, err := recordByName(name)
+	if err != nil {
+		return nil, errors.New("cannot get key from db")
+	}
+	return record.Key, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pkg/errors"
+)
+
+// webSeedWait is how long a torrent may go without any peer before the web seeds are used
+var webSeedWait = time.Minute
+
+// webSeeds returns the BEP-19 web seed urls carried as ws parameters of a magnet link
+func webSeeds(magnet string) []string {
+	u, err := url.Parse(magnet)
+	if err != nil {
+		return nil
+	}
+	return u.Query()["ws"]
+}
+
+// fileURL follows BEP-19: a url ending in a slash is a directory the torrent name
+// (and for multi-file torrents the file path) is appended to
+func fileURL(base string, info *metainfo.Info, fi metainfo.FileInfo) string {
+	if !strings.HasSuffix(base, "/") {
+		return base
+	}
+	parts := []string{url.PathEscape(info.Name)}
+	if info.IsDir() {
+		for _, p := range fi.Path {
+			parts = append(parts, url.PathEscape(p))
+		}
+	}
+	return base + strings.Join(parts, "/")
+}
+
+func getRange(client *http.Client, u string, off, n int64) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+n-1))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, errors.Errorf("%s: %s", u, resp.Status)
+	}
+	buf := make([]byte, n)
+	_, err = io.ReadFull(resp.Body, buf)
+	return buf, err
+}
+
+// fetchPiece downloads piece i from one web seed. The bytes of a piece may span
+// several files of a multi-file torrent.
+func fetchPiece(client *http.Client, base string, info *metainfo.Info, i int) ([]byte, error) {
+	p := info.Piece(i)
+	begin, end := p.Offset(), p.Offset()+p.Length()
+	var buf bytes.Buffer
+	var fileBegin int64
+	for _, fi := range info.UpvertedFiles() {
+		fileEnd := fileBegin + fi.Length
+		if fileEnd > begin && fileBegin < end {
+			off := begin - fileBegin
+			if off < 0 {
+				off = 0
+			}
+			n := fi.Length - off
+			if rest := end - (fileBegin + off); rest < n {
+				n = rest
+			}
+			data, err := getRange(client, fileURL(base, info, fi), off, n)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(data)
+		}
+		fileBegin = fileEnd
+	}
+	if sha1.Sum(buf.Bytes()) != p.Hash() {
+		return nil, errors.Errorf("piece %d from %s does not match its hash", i, base)
+	}
+	return buf.Bytes(), nil
+}
+
+// webSeedFallback fills the pieces of t from the web seeds while no peer is connected.
+// Pieces are checked against the info hashes before they are written and verified
+// again by the torrent client afterwards.
+func webSeedFallback(t *torrent.Torrent, seeds []string) {
+	if len(seeds) == 0 {
+		return
+	}
+	<-t.GotInfo()
+	info := t.Info()
+	client := &http.Client{Timeout: time.Minute}
+	for t.BytesCompleted() < info.TotalLength() {
+		select {
+		case <-t.Closed():
+			return
+		case <-time.After(webSeedWait):
+		}
+		if t.Stats().ActivePeers > 0 {
+			continue
+		}
+		fmt.Println("no peers for", t.Name(), "falling back to web seeds")
+		for i := 0; i < info.NumPieces(); i++ {
+			if t.PieceState(i).Complete {
+				continue
+			}
+			for _, base := range seeds {
+				data, err := fetchPiece(client, base, info, i)
+				if err != nil {
+					fmt.Println(err)
+					continue
+				}
+				piece := t.Piece(i)
+				if _, err := piece.Storage().WriteAt(data, 0); err != nil {
+					fmt.Println(err)
+					continue
+				}
+				piece.VerifyData()
+				break
+			}
+		}
+	}
+}
@@ -6,60 +6,24 @@ import (
     "encoding/pem"
     "fmt"
     "crypto/x509"
+    "strconv"
     "github.com/hyperledger/fabric/core/chaincode/shim"
     sc "github.com/hyperledger/fabric/protos/peer"
+    "github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
 )
 
 type SmartContract struct {
 
 }
 
-type Request struct {
-    From string `json:"from"`
-    To string `json:"to"`
-    File string `json:"file"`
-    RequestTime int64 `json:"requestTime"`
-    ResponseTime int64 `json:"responseTime"`
-    ConfirmationTime int64 `json:"confirmationTime"`
-}
-
-type RequestMessage struct {
-    From string `json:"from"`
-    To string `json:"to"`
-    File string `json:"file"`
-    TxID string `json:"tx_id"`
-    RequestTime int64 `json:"requestTime"`
-}
-
-type ResponseMessage struct {
-    From string `json:"from"`
-    To []string `json:"to"`
-    File string `json:"file"`
-    TxID []string `json:"tx_id"`
-    Secret string `json:"secret"`
-    ResponseTime int64 `json:responseTime`
-}
-
-type ConfirmationMessage struct {
-    TxID string `json:"tx_id"`
-    ConfirmationTime int64 `json:"confirmationTime"`
-}
-
-type File struct {
-    Name string `json:"name"`
-    Hash string `json:"hash"`
-    Keyword string `json:"keyword"`
-    Summary string `json:"summary"`
-    Owner string `json:"owner"`
-    Locktime int64 `json:"locktime"`
-    Magnet string
-    AESKey string `tempory`
-}
-
 /*
  * Init function: necessary
  */
 func (s *SmartContract) Init(APIstub shim.ChaincodeStubInterface) sc.Response {
+    // Init runs again on upgrade, bring the state of the previous version up to date
+    if err := migrate(APIstub); err != nil {
+        return shim.Error(err.Error())
+    }
     return shim.Success(nil)
 }
 
@@ -78,6 +42,20 @@ func (s *SmartContract) Invoke(APIstub shim.ChaincodeStubInterface) sc.Response
         return s.confirmSecret(APIstub, args)
     } else if function == "queryRequest" {
         return s.queryRequest(APIstub, args)
+    } else if function == "revokeRequests" {
+        return s.revokeRequests(APIstub, args)
+    } else if function == "depositShares" {
+        return s.depositShares(APIstub, args)
+    } else if function == "queryShares" {
+        return s.queryShares(APIstub, args)
+    } else if function == "queryShare" {
+        return s.queryShare(APIstub, args)
+    } else if function == "respondShare" {
+        return s.respondShare(APIstub, args)
+    } else if function == "escrowSecret" {
+        return s.escrowSecret(APIstub, args)
+    } else if function == "queryEscrow" {
+        return s.queryEscrow(APIstub, args)
     }
 
     return shim.Error("Invalid Smart Contract function name.")
@@ -86,8 +64,13 @@ func (s *SmartContract) Invoke(APIstub shim.ChaincodeStubInterface) sc.Response
 
 func (s *SmartContract) requestSecret(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
 
-    if len(args) != 3 {
-        return shim.Error("Incorrect number of arguments. Expecting 3 keys of file")
+    if len(args) != 3 && len(args) != 4 {
+        return shim.Error("Incorrect number of arguments. Expecting 3 keys of file and optional public key to wrap the secret for")
+    }
+    // public key of the requester, the owner encrypts the secret for it
+    pubKey := ""
+    if len(args) == 4 {
+        pubKey = args[3]
     }
 
     uname, err := s.testCertificate(APIstub, nil)
@@ -96,7 +79,7 @@ func (s *SmartContract) requestSecret(APIstub shim.ChaincodeStubInterface, args
     }
     // produce the composite key for file
     keys := []string{args[0], args[1], args[2]}
-    ckey, err := APIstub.CreateCompositeKey("File", keys)
+    ckey, err := APIstub.CreateCompositeKey(catalog.FileType, keys)
     if err != nil {
         return shim.Error(err.Error())
     }
@@ -115,6 +98,11 @@ func (s *SmartContract) requestSecret(APIstub shim.ChaincodeStubInterface, args
         }
     }
 
+    // the access policy of the file must allow the requester
+    if err := s.testAccess(APIstub, ckey, uname); err != nil {
+        return shim.Error(err.Error())
+    }
+
     // check the existence of the file
     argsByBytes = [][]byte{[]byte("queryFile"), []byte(args[0]), []byte(args[1]), []byte(args[2])}
     res = APIstub.InvokeChaincode("myapp", argsByBytes, "")
@@ -133,13 +121,19 @@ func (s *SmartContract) requestSecret(APIstub shim.ChaincodeStubInterface, args
     }
 
     // put request record
-    var request = Request{From: uname, To: args[2], File: ckey, RequestTime: timestamp.GetSeconds(), ResponseTime: 0, ConfirmationTime: 0}
+    var request = catalog.Request{From: uname, To: args[2], File: ckey, RequestTime: timestamp.GetSeconds(), ResponseTime: 0, ConfirmationTime: 0, PubKey: pubKey}
     requestAsBytes, _ := json.Marshal(request)
 
     APIstub.PutState(tx_id, requestAsBytes)
+    // index the request by file so they can be revoked when the file key changes
+    indexKey, err := APIstub.CreateCompositeKey("Request~file", []string{ckey, tx_id})
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    APIstub.PutState(indexKey, []byte{0x00})
 
     // broadcast an event
-    var message = RequestMessage{From: uname, To: args[2], File: ckey, TxID: tx_id, RequestTime: timestamp.GetSeconds()}
+    var message = catalog.RequestMessage{From: uname, To: args[2], File: ckey, TxID: tx_id, RequestTime: timestamp.GetSeconds(), PubKey: pubKey}
     messageAsBytes, _ := json.Marshal(message)
     APIstub.SetEvent("requestSecret", messageAsBytes)
 
@@ -165,7 +159,7 @@ func (s *SmartContract) respondSecret(APIstub shim.ChaincodeStubInterface, args
     for _, req := range args[:len(args)-1] {
         // get the request record by tx_id
         requestAsBytes, err := APIstub.GetState(req)
-        request := Request{}
+        request := catalog.Request{}
         json.Unmarshal(requestAsBytes, &request)
 
         if fileKey == "" {
@@ -178,6 +172,13 @@ func (s *SmartContract) respondSecret(APIstub shim.ChaincodeStubInterface, args
         if uname != request.To {
             return shim.Error("Wrong transaction ID")
         }
+        if request.RevocationTime != 0 {
+            return shim.Error("This request has been revoked")
+        }
+        // the access policy may have changed since the request
+        if err := s.testAccess(APIstub, request.File, request.From); err != nil {
+            return shim.Error(err.Error())
+        }
 
         fromList = append(fromList, request.From)
 
@@ -193,7 +194,7 @@ func (s *SmartContract) respondSecret(APIstub shim.ChaincodeStubInterface, args
             return shim.Error("This request already has a response")
         }
         requestAsBytes, _ = json.Marshal(request)
-        APIstub.PutState(args[0], requestAsBytes)
+        APIstub.PutState(req, requestAsBytes)
     }
 
     argsByBytes := [][]byte{[]byte("addLocktime"), []byte(fileKey)}
@@ -203,7 +204,7 @@ func (s *SmartContract) respondSecret(APIstub shim.ChaincodeStubInterface, args
     }
 
     // broadcast an event
-    var message = ResponseMessage{From: uname, To: fromList, File: fileKey, TxID: args[:len(args)-1], Secret: args[len(args)-1], ResponseTime: timestampInt}
+    var message = catalog.ResponseMessage{From: uname, To: fromList, File: fileKey, TxID: args[:len(args)-1], Secret: args[len(args)-1], ResponseTime: timestampInt}
     messageAsBytes, _ := json.Marshal(message)
     APIstub.SetEvent("respondSecret", messageAsBytes)
 
@@ -224,7 +225,7 @@ func (s *SmartContract) confirmSecret(APIstub shim.ChaincodeStubInterface, args
 
     // get the request record by tx_id
     requestAsBytes, err := APIstub.GetState(args[0])
-    request := Request{}
+    request := catalog.Request{}
     json.Unmarshal(requestAsBytes, &request)
 
     // check
@@ -232,17 +233,24 @@ func (s *SmartContract) confirmSecret(APIstub shim.ChaincodeStubInterface, args
         return shim.Error("Wrong transaction ID")
     }
 
-    // test Locktime
-    argsByBytes := [][]byte{[]byte("externalTestLocktime"), []byte(request.File)}
-    res := APIstub.InvokeChaincode("myapp", argsByBytes, "")
-    if res.Status > 400 {
-        return shim.Error(res.Message)
-    } else if len(res.Payload) <= 0 {
-        return shim.Error("The file is not exist")
-    } else {
-        ret := string(res.Payload)
-        if ret != "2" {
-            return shim.Error("The file is locked for confirm")
+    // a key recovered from enough shares is confirmed without an answer of the owner
+    config, err := s.getShareConfig(APIstub, request.File)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    if config == nil || len(request.Shares) < config.Threshold {
+        // test Locktime
+        argsByBytes := [][]byte{[]byte("externalTestLocktime"), []byte(request.File)}
+        res := APIstub.InvokeChaincode("myapp", argsByBytes, "")
+        if res.Status > 400 {
+            return shim.Error(res.Message)
+        } else if len(res.Payload) <= 0 {
+            return shim.Error("The file is not exist")
+        } else {
+            ret := string(res.Payload)
+            if ret != "2" {
+                return shim.Error("The file is locked for confirm")
+            }
         }
     }
 
@@ -259,7 +267,7 @@ func (s *SmartContract) confirmSecret(APIstub shim.ChaincodeStubInterface, args
     requestAsBytes, _ = json.Marshal(request)
     APIstub.PutState(args[0], requestAsBytes)
 
-    var message = ConfirmationMessage{TxID: args[0], ConfirmationTime: timestamp.GetSeconds()} 
+    var message = catalog.ConfirmationMessage{TxID: args[0], ConfirmationTime: timestamp.GetSeconds()} 
     messageAsBytes, _ := json.Marshal(message)
     APIstub.SetEvent("confirmSecret", messageAsBytes)
 
@@ -267,9 +275,342 @@ func (s *SmartContract) confirmSecret(APIstub shim.ChaincodeStubInterface, args
 }
 
 
+// revokeRequests marks every unanswered request for a file as revoked, so it is not
+// answered with a key that is no longer in use. Only the owner of the file may call it.
+func (s *SmartContract) revokeRequests(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+    if len(args) != 3 {
+        return shim.Error("Incorrect number of arguments. Expecting 3 keys of file")
+    }
+
+    uname, err := s.testCertificate(APIstub, nil)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    if uname != args[2] {
+        return shim.Error("Permission denied")
+    }
+
+    ckey, err := APIstub.CreateCompositeKey(catalog.FileType, []string{args[0], args[1], args[2]})
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    timestamp, err := APIstub.GetTxTimestamp()
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+
+    resultsIterator, err := APIstub.GetStateByPartialCompositeKey("Request~file", []string{ckey})
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    defer resultsIterator.Close()
+
+    var revoked []string
+    for resultsIterator.HasNext() {
+        queryResponse, err := resultsIterator.Next()
+        if err != nil {
+            return shim.Error(err.Error())
+        }
+        _, attributes, err := APIstub.SplitCompositeKey(queryResponse.Key)
+        if err != nil {
+            return shim.Error(err.Error())
+        }
+        tx_id := attributes[1]
+
+        requestAsBytes, err := APIstub.GetState(tx_id)
+        if err != nil {
+            return shim.Error(err.Error())
+        }
+        request := catalog.Request{}
+        json.Unmarshal(requestAsBytes, &request)
+        if request.ResponseTime == 0 && request.RevocationTime == 0 {
+            request.RevocationTime = timestamp.GetSeconds()
+            requestAsBytes, _ = json.Marshal(request)
+            APIstub.PutState(tx_id, requestAsBytes)
+            revoked = append(revoked, tx_id)
+        }
+        // answered or revoked requests need no index anymore
+        APIstub.DelState(queryResponse.Key)
+    }
+
+    var message = catalog.RevocationMessage{File: ckey, TxID: revoked, RevocationTime: timestamp.GetSeconds()}
+    messageAsBytes, _ := json.Marshal(message)
+    APIstub.SetEvent("revokeSecret", messageAsBytes)
+
+    return shim.Success(messageAsBytes)
+}
+
+
+func (s *SmartContract) getShareConfig(APIstub shim.ChaincodeStubInterface, ckey string) (*catalog.ShareConfig, error) {
+    configKey, err := APIstub.CreateCompositeKey("Shares", []string{ckey})
+    if err != nil {
+        return nil, err
+    }
+    configAsBytes, err := APIstub.GetState(configKey)
+    if err != nil || configAsBytes == nil {
+        return nil, err
+    }
+    config := catalog.ShareConfig{}
+    if err := json.Unmarshal(configAsBytes, &config); err != nil {
+        return nil, err
+    }
+    return &config, nil
+}
+
+
+// depositShares splits the responsibility of answering requests for a file among
+// several holders, any threshold of which give a requester enough shares to recover
+// the key. Each share is wrapped for its holder. Only the owner of the file may call it.
+// args: 3 keys of file, threshold, then pairs of holder and wrapped share
+func (s *SmartContract) depositShares(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+    if len(args) < 6 || len(args)%2 != 0 {
+        return shim.Error("Incorrect number of arguments. Expecting 3 keys of file, threshold and pairs of holder and share")
+    }
+
+    uname, err := s.testCertificate(APIstub, nil)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    if uname != args[2] {
+        return shim.Error("Permission denied")
+    }
+
+    ckey, err := APIstub.CreateCompositeKey(catalog.FileType, []string{args[0], args[1], args[2]})
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    threshold, err := strconv.Atoi(args[3])
+    if err != nil {
+        return shim.Error("threshold must be an integer")
+    }
+    pairs := args[4:]
+    if threshold < 1 || threshold > len(pairs)/2 {
+        return shim.Error("threshold must be between 1 and the number of holders")
+    }
+
+    // the shares of a previous key must not outlive it
+    old, err := s.getShareConfig(APIstub, ckey)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    if old != nil {
+        for _, holder := range old.Holders {
+            shareKey, _ := APIstub.CreateCompositeKey("Share", []string{ckey, holder})
+            APIstub.DelState(shareKey)
+        }
+    }
+
+    config := catalog.ShareConfig{Threshold: threshold}
+    for i := 0; i < len(pairs); i += 2 {
+        holder := pairs[i]
+        for _, h := range config.Holders {
+            if h == holder {
+                return shim.Error("Duplicate holder " + holder)
+            }
+        }
+        config.Holders = append(config.Holders, holder)
+        shareKey, err := APIstub.CreateCompositeKey("Share", []string{ckey, holder})
+        if err != nil {
+            return shim.Error(err.Error())
+        }
+        APIstub.PutState(shareKey, []byte(pairs[i+1]))
+    }
+
+    configKey, err := APIstub.CreateCompositeKey("Shares", []string{ckey})
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    configAsBytes, _ := json.Marshal(config)
+    APIstub.PutState(configKey, configAsBytes)
+
+    return shim.Success(configAsBytes)
+}
+
+
+// queryShares returns the share configuration of a file, empty without one. args: 3 keys of file
+func (s *SmartContract) queryShares(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+    if len(args) != 3 {
+        return shim.Error("Incorrect number of arguments. Expecting 3 keys of file")
+    }
+    ckey, err := APIstub.CreateCompositeKey(catalog.FileType, []string{args[0], args[1], args[2]})
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    config, err := s.getShareConfig(APIstub, ckey)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    if config == nil {
+        return shim.Success(nil)
+    }
+    configAsBytes, _ := json.Marshal(config)
+    return shim.Success(configAsBytes)
+}
+
+
+// queryShare returns the share of the calling holder, still wrapped for it. args: 3 keys of file
+func (s *SmartContract) queryShare(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+    if len(args) != 3 {
+        return shim.Error("Incorrect number of arguments. Expecting 3 keys of file")
+    }
+
+    uname, err := s.testCertificate(APIstub, nil)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    ckey, err := APIstub.CreateCompositeKey(catalog.FileType, []string{args[0], args[1], args[2]})
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    shareKey, err := APIstub.CreateCompositeKey("Share", []string{ckey, uname})
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    share, err := APIstub.GetState(shareKey)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    if share == nil {
+        return shim.Error(uname + " holds no share of the file")
+    }
+    return shim.Success(share)
+}
+
+
+// respondShare delivers the share of the calling holder to a requester and records
+// the delivery on the request. args: tx_id, share wrapped for the requester
+func (s *SmartContract) respondShare(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+    if len(args) != 2 {
+        return shim.Error("Incorrect number of arguments. Expecting tx_id and share")
+    }
+
+    uname, err := s.testCertificate(APIstub, nil)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+
+    requestAsBytes, err := APIstub.GetState(args[0])
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    if requestAsBytes == nil {
+        return shim.Error("Wrong transaction ID")
+    }
+    request := catalog.Request{}
+    json.Unmarshal(requestAsBytes, &request)
+    if request.RevocationTime != 0 {
+        return shim.Error("This request has been revoked")
+    }
+    if err := s.testAccess(APIstub, request.File, request.From); err != nil {
+        return shim.Error(err.Error())
+    }
+
+    config, err := s.getShareConfig(APIstub, request.File)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    isHolder := false
+    if config != nil {
+        for _, h := range config.Holders {
+            isHolder = isHolder || h == uname
+        }
+    }
+    if !isHolder {
+        return shim.Error(uname + " holds no share of the file")
+    }
+    if _, ok := request.Shares[uname]; ok {
+        return shim.Error("This share has already been delivered")
+    }
+
+    timestamp, err := APIstub.GetTxTimestamp()
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    if request.Shares == nil {
+        request.Shares = make(map[string]int64)
+    }
+    request.Shares[uname] = timestamp.GetSeconds()
+    requestAsBytes, _ = json.Marshal(request)
+    APIstub.PutState(args[0], requestAsBytes)
+
+    var message = catalog.ShareMessage{From: uname, To: request.From, File: request.File, TxID: args[0], Share: args[1], Delivered: len(request.Shares), Threshold: config.Threshold}
+    messageAsBytes, _ := json.Marshal(message)
+    APIstub.SetEvent("respondShare", messageAsBytes)
+
+    return shim.Success(nil)
+}
+
+
+// escrowSecret stores the key of a file wrapped for a recovery identity of the owner's
+// organisation, so the file stays readable if the owner loses its keys.
+// args: 3 keys of file, recovery identity, wrapped secret
+func (s *SmartContract) escrowSecret(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+    if len(args) != 5 {
+        return shim.Error("Incorrect number of arguments. Expecting 3 keys of file, recovery identity and wrapped secret")
+    }
+
+    uname, err := s.testCertificate(APIstub, nil)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    if uname != args[2] {
+        return shim.Error("Permission denied")
+    }
+
+    ckey, err := APIstub.CreateCompositeKey(catalog.FileType, []string{args[0], args[1], args[2]})
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    escrowKey, err := APIstub.CreateCompositeKey("Escrow", []string{ckey, args[3]})
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    APIstub.PutState(escrowKey, []byte(args[4]))
+
+    return shim.Success(nil)
+}
+
+
+// queryEscrow returns the secret escrowed for the calling identity. args: 3 keys of file
+func (s *SmartContract) queryEscrow(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+    if len(args) != 3 {
+        return shim.Error("Incorrect number of arguments. Expecting 3 keys of file")
+    }
+
+    uname, err := s.testCertificate(APIstub, nil)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+
+    ckey, err := APIstub.CreateCompositeKey(catalog.FileType, []string{args[0], args[1], args[2]})
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    escrowKey, err := APIstub.CreateCompositeKey("Escrow", []string{ckey, uname})
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    secret, err := APIstub.GetState(escrowKey)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    if secret == nil {
+        return shim.Error("No secret escrowed for " + uname)
+    }
+    return shim.Success(secret)
+}
+
+
 func (s *SmartContract) queryRequest(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
 
-    if len(args) > 1 {
+    if len(args) != 1 {
         return shim.Error("Incorrect number of arguments. Expacting only transaction id")
     }
 
@@ -280,6 +621,9 @@ func (s *SmartContract) queryRequest(APIstub shim.ChaincodeStubInterface, args [
     if err != nil {
         return shim.Error(err.Error())
     }
+    if queryResponse == nil {
+        return shim.Error("The request is not exist")
+    }
     buffer.WriteString("{\"Key\":\"")
     if err != nil {
         return shim.Error(err.Error())
@@ -293,6 +637,17 @@ func (s *SmartContract) queryRequest(APIstub shim.ChaincodeStubInterface, args [
 }
 
 
+// testAccess fails unless the access policy of the file of ckey allows user to receive its key.
+// Every path handing out a key or a share of it checks it.
+func (s *SmartContract) testAccess(APIstub shim.ChaincodeStubInterface, ckey string, user string) error {
+    argsByBytes := [][]byte{[]byte("externalTestAccess"), []byte(ckey), []byte(user)}
+    res := APIstub.InvokeChaincode("myapp", argsByBytes, "")
+    if res.Status > 400 {
+        return fmt.Errorf("%s", res.Message)
+    }
+    return nil
+}
+
 func (s *SmartContract) testCertificate(stub shim.ChaincodeStubInterface, args []string ) (string, error) {
     creatorByte, _ := stub.GetCreator()
     certStart := bytes.IndexAny(creatorByte, "-----BEGIN")
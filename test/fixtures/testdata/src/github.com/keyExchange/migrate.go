@@ -0,0 +1,81 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "strconv"
+
+    "github.com/hyperledger/fabric/core/chaincode/shim"
+    "github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+)
+
+// schemaType is the object type of the key holding how many migrations the state went through
+const schemaType = "Schema"
+
+/*
+ * migrations bring the state written by earlier versions of the chaincode up to date.
+ * Init runs those the state has not gone through on instantiate and upgrade, so append
+ * new ones and never reorder or remove them. They must not depend on the Init arguments.
+ */
+var migrations = []func(shim.ChaincodeStubInterface) error{
+    indexPendingRequests,
+}
+
+func migrate(APIstub shim.ChaincodeStubInterface) error {
+    schemaKey, err := APIstub.CreateCompositeKey(schemaType, []string{})
+    if err != nil {
+        return err
+    }
+    schemaAsBytes, err := APIstub.GetState(schemaKey)
+    if err != nil {
+        return err
+    }
+    done := 0
+    if len(schemaAsBytes) > 0 {
+        if done, err = strconv.Atoi(string(schemaAsBytes)); err != nil {
+            return fmt.Errorf("invalid schema version %q", schemaAsBytes)
+        }
+    }
+    if done > len(migrations) {
+        return fmt.Errorf("the state is at schema %d, this chaincode only knows %d", done, len(migrations))
+    }
+    for i := done; i < len(migrations); i++ {
+        if err := migrations[i](APIstub); err != nil {
+            return fmt.Errorf("migration %d failed: %s", i+1, err)
+        }
+    }
+    return APIstub.PutState(schemaKey, []byte(strconv.Itoa(len(migrations))))
+}
+
+// indexPendingRequests indexes by file the pending requests made before requests were
+// indexed, so revokeRequests finds them
+func indexPendingRequests(APIstub shim.ChaincodeStubInterface) error {
+    // requests are the only records under a simple key, the tx_id
+    resultsIterator, err := APIstub.GetStateByRange("", "")
+    if err != nil {
+        return err
+    }
+    defer resultsIterator.Close()
+
+    for resultsIterator.HasNext() {
+        queryResponse, err := resultsIterator.Next()
+        if err != nil {
+            return err
+        }
+        request := catalog.Request{}
+        if json.Unmarshal(queryResponse.Value, &request) != nil || request.File == "" {
+            continue
+        }
+        if request.ResponseTime != 0 || request.RevocationTime != 0 {
+            continue
+        }
+        indexKey, err := APIstub.CreateCompositeKey("Request~file", []string{request.File, queryResponse.Key})
+        if err != nil {
+            return err
+        }
+        if err := APIstub.PutState(indexKey, []byte{0x00}); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "strconv"
+
+    "github.com/hyperledger/fabric/core/chaincode/shim"
+    "github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
+)
+
+// schemaType is the object type of the key holding how many migrations the state went through
+const schemaType = "Schema"
+
+/*
+ * migrations bring the state written by earlier versions of the chaincode up to date.
+ * Init runs those the state has not gone through on instantiate and upgrade, so append
+ * new ones and never reorder or remove them. They must not depend on the Init arguments.
+ */
+var migrations = []func(shim.ChaincodeStubInterface) error{
+    numberFileVersions,
+    dropFileKeys,
+}
+
+func migrate(APIstub shim.ChaincodeStubInterface) error {
+    schemaKey, err := APIstub.CreateCompositeKey(schemaType, []string{})
+    if err != nil {
+        return err
+    }
+    schemaAsBytes, err := APIstub.GetState(schemaKey)
+    if err != nil {
+        return err
+    }
+    done := 0
+    if len(schemaAsBytes) > 0 {
+        if done, err = strconv.Atoi(string(schemaAsBytes)); err != nil {
+            return fmt.Errorf("invalid schema version %q", schemaAsBytes)
+        }
+    }
+    if done > len(migrations) {
+        return fmt.Errorf("the state is at schema %d, this chaincode only knows %d", done, len(migrations))
+    }
+    for i := done; i < len(migrations); i++ {
+        if err := migrations[i](APIstub); err != nil {
+            return fmt.Errorf("migration %d failed: %s", i+1, err)
+        }
+    }
+    return APIstub.PutState(schemaKey, []byte(strconv.Itoa(len(migrations))))
+}
+
+// numberFileVersions records version 1 on the files created before versions were counted
+func numberFileVersions(APIstub shim.ChaincodeStubInterface) error {
+    resultsIterator, err := APIstub.GetStateByPartialCompositeKey(catalog.FileType, []string{})
+    if err != nil {
+        return err
+    }
+    defer resultsIterator.Close()
+
+    for resultsIterator.HasNext() {
+        queryResponse, err := resultsIterator.Next()
+        if err != nil {
+            return err
+        }
+        file, err := catalog.DecodeFile(queryResponse.Value)
+        if err != nil {
+            return err
+        }
+        if file.Version != 0 {
+            continue
+        }
+        file.Version = 1
+        fileAsBytes, _ := json.Marshal(file)
+        if err := APIstub.PutState(queryResponse.Key, fileAsBytes); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// dropFileKeys removes the keys earlier versions stored in the clear with the files. They
+// remain in the history of the ledger, the keys of those files have to be rotated.
+func dropFileKeys(APIstub shim.ChaincodeStubInterface) error {
+    resultsIterator, err := APIstub.GetStateByPartialCompositeKey(catalog.FileType, []string{})
+    if err != nil {
+        return err
+    }
+    defer resultsIterator.Close()
+
+    for resultsIterator.HasNext() {
+        queryResponse, err := resultsIterator.Next()
+        if err != nil {
+            return err
+        }
+        // File has no key anymore, decoding it leaves the key out
+        file, err := catalog.DecodeFile(queryResponse.Value)
+        if err != nil {
+            return err
+        }
+        fileAsBytes, _ := json.Marshal(file)
+        if err := APIstub.PutState(queryResponse.Key, fileAsBytes); err != nil {
+            return err
+        }
+    }
+    return nil
+}
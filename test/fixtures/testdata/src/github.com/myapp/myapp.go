@@ -5,30 +5,25 @@ import (
     "encoding/json"
     "encoding/pem"
     "fmt"
+    "strconv"
     "crypto/x509"
     "github.com/hyperledger/fabric/core/chaincode/shim"
     sc "github.com/hyperledger/fabric/protos/peer"
+    "github.com/hyperledger/fabric-sdk-go/test/fabric_torrent/catalog"
 )
 
 type SmartContract struct {
 
 }
 
-type File struct {
-    Name string `json:"name"`
-    Hash string `json:"hash"`
-    Keyword string `json:"keyword"`
-    Summary string `json:"summary"`
-    Owner string `json:"owner"`
-    Locktime int64 `json:"locktime"`
-    Magnet string
-    AESKey string `tempory`
-}
-
 /*
 * Init function: necessary
  */
 func (s *SmartContract) Init(APIstub shim.ChaincodeStubInterface) sc.Response {
+    // Init runs again on upgrade, bring the state of the previous version up to date
+    if err := migrate(APIstub); err != nil {
+        return shim.Error(err.Error())
+    }
     return shim.Success(nil)
 }
 
@@ -53,6 +48,14 @@ func (s *SmartContract) Invoke(APIstub shim.ChaincodeStubInterface) sc.Response
         return s.addLocktime(APIstub, args)
     } else if function == "getAllMagnet"{
         return s.getAllMagnet(APIstub)
+    } else if function == "queryFileRecords" {
+        return s.queryFileRecords(APIstub, args)
+    } else if function == "updateFile" {
+        return s.updateFile(APIstub, args)
+    } else if function == "updateFileMeta" {
+        return s.updateFileMeta(APIstub, args)
+    } else if function == "externalTestAccess" {
+        return s.externalTestAccess(APIstub, args)
     }
 
     return shim.Error("Invalid Smart Contract function name.")
@@ -64,14 +67,21 @@ func (s *SmartContract) Invoke(APIstub shim.ChaincodeStubInterface) sc.Response
  */
 func (s *SmartContract) createFile(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
 
+    if len(args) < 6 {
+        return shim.Error("Incorrect number of arguments. Expecting name, hash, keyword, summary, magnet, an empty key, optional size, bencoded info, cipher and access")
+    }
+    // keys are only handed out wrapped through keyExchange, the ledger never holds one
+    if args[5] != "" {
+        return shim.Error("The key of a file is not stored, leave it empty")
+    }
+
     uname, err := s.testCertificate(APIstub, nil)
     if err != nil {
         return shim.Error(err.Error())
     }
 
     //check if exist a file with same name
-    //haven't tested
-    resultsIterator, err := APIstub.GetStateByPartialCompositeKey("File",[]string{args[0]})
+    resultsIterator, err := APIstub.GetStateByPartialCompositeKey(catalog.FileType, []string{args[2], args[0], uname})
     if err != nil {
         return shim.Error(err.Error())
     }
@@ -82,8 +92,23 @@ func (s *SmartContract) createFile(APIstub shim.ChaincodeStubInterface, args []s
     }
 
     // create an object
-    //var file = File{Name: args[0], Hash: args[1], Keyword: args[2], Summary: args[3], Owner: uname, Locktime: 0,Magnet:args[4]}
-    var file = File{Name: args[0], Hash: args[1], Keyword: args[2], Summary: args[3], Owner: uname, Locktime: 0,Magnet:args[4],AESKey:args[5]}
+    //var file = catalog.File{Name: args[0], Hash: args[1], Keyword: args[2], Summary: args[3], Owner: uname, Locktime: 0,Magnet:args[4]}
+    var file = catalog.File{Name: args[0], Hash: args[1], Keyword: args[2], Summary: args[3], Owner: uname, Locktime: 0,Magnet:args[4],Version:1}
+    if len(args) > 6 {
+        file.Size, err = strconv.ParseInt(args[6], 10, 64)
+        if err != nil {
+            return shim.Error("size must be an integer")
+        }
+    }
+    if len(args) > 7 && args[7] != "" {
+        file.Info = []byte(args[7])
+    }
+    if len(args) > 8 {
+        file.Cipher = args[8]
+    }
+    if len(args) > 9 {
+        file.Access = args[9]
+    }
     fileAsBytes, _ := json.Marshal(file)
 
     // we need a relational database as an addition to leveldb
@@ -94,7 +119,7 @@ func (s *SmartContract) createFile(APIstub shim.ChaincodeStubInterface, args []s
     //args[0]: Name
     //uname:
     keys := []string{args[2], args[0], uname}
-    ckey, err := APIstub.CreateCompositeKey("File", keys)
+    ckey, err := APIstub.CreateCompositeKey(catalog.FileType, keys)
     if err != nil {
         return shim.Error(err.Error())
     }
@@ -106,7 +131,8 @@ func (s *SmartContract) createFile(APIstub shim.ChaincodeStubInterface, args []s
 
 
 /*
- *queryFile function: query File by at least one at most three keys
+ *queryFile function: query File by at least one at most three keys, returns the name of the
+ *first matching file
  */
 func (s *SmartContract) queryFile(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
 
@@ -115,7 +141,7 @@ func (s *SmartContract) queryFile(APIstub shim.ChaincodeStubInterface, args []st
     }
 
     // get query result
-    resultsIterator, err := APIstub.GetStateByPartialCompositeKey("File", args)
+    resultsIterator, err := APIstub.GetStateByPartialCompositeKey(catalog.FileType, args)
     if err != nil {
         return shim.Error(err.Error())
     }
@@ -130,12 +156,9 @@ func (s *SmartContract) queryFile(APIstub shim.ChaincodeStubInterface, args []st
 
     if resultsIterator.HasNext() {
     	kv,_:=resultsIterator.Next()
-    	file:=File{}
+    	file:=catalog.File{}
     	json.Unmarshal(kv.Value,&file)
-    	res:=make([][]byte,2)
-        res[0]=[]byte(file.Name)
-    	res[1]=[]byte(file.AESKey)
-    	return shim.Success(bytes.Join(res,[]byte(",")))
+    	return shim.Success([]byte(file.Name))
         //queryResponse, err := resultsIterator.Next()
         //if err != nil {
         //    return shim.Error(err.Error())
@@ -158,17 +181,57 @@ func (s *SmartContract) queryFile(APIstub shim.ChaincodeStubInterface, args []st
     }
     //buffer.WriteString("]")
 
-    return shim.Error("no key")
+    return shim.Error("The file is not exist")
     //return shim.Success(buffer.Bytes())
 }
 
 
 /*
- * changeFileOwner function: change owner of a file. must provide complete composite key
+ * queryFileRecords function: like queryFile but returns every matching record as a JSON array
+ * of {"Key":{"objectType":"File","attributes":[keyword,name,owner]},"Record":{...}}
  */
-func (s *SmartContract) changeFileOwner(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
-    if len(args) != 4 {
-        return shim.Error("Incorrect number of arguments. Expecting 3 keys and 1 new owner")
+func (s *SmartContract) queryFileRecords(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+    if len(args) > 3 {
+        return shim.Error("Incorrect number of arguments. Expecting at most keyword, name and owner")
+    }
+
+    resultsIterator, err := APIstub.GetStateByPartialCompositeKey(catalog.FileType, args)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    defer resultsIterator.Close()
+
+    records := []catalog.Record{}
+    for resultsIterator.HasNext() {
+        queryResponse, err := resultsIterator.Next()
+        if err != nil {
+            return shim.Error(err.Error())
+        }
+        typeString, keys, err := APIstub.SplitCompositeKey(queryResponse.Key)
+        if err != nil {
+            return shim.Error(err.Error())
+        }
+        record := catalog.Record{Key: catalog.RecordKey{ObjectType: typeString, Attributes: keys}}
+        json.Unmarshal(queryResponse.Value, &record.Record)
+        records = append(records, record)
+    }
+    recordsAsBytes, _ := json.Marshal(records)
+
+    return shim.Success(recordsAsBytes)
+}
+
+
+/*
+ * updateFile function: replace hash and magnet of a published file whose content changed.
+ * must provide complete composite key
+ */
+func (s *SmartContract) updateFile(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
+    if len(args) < 6 || len(args) > 9 {
+        return shim.Error("Incorrect number of arguments. Expecting 3 keys, hash, magnet, an empty key, optional size, bencoded info and cipher")
+    }
+    if args[5] != "" {
+        return shim.Error("The key of a file is not stored, leave it empty")
     }
 
     uname, err := s.testCertificate(APIstub, nil)
@@ -176,21 +239,133 @@ func (s *SmartContract) changeFileOwner(APIstub shim.ChaincodeStubInterface, arg
         return shim.Error(err.Error())
     }
 
+    keys := []string{args[0], args[1], args[2]}
+    ckey, err := APIstub.CreateCompositeKey(catalog.FileType, keys)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+
     // test Locktime
-    timeflag := s.testLocktime(APIstub, []string{args[0], args[1], args[2]})
+    timeflag := s.testLocktime(APIstub, []string{ckey})
     if timeflag >= 2 {
         return shim.Error("The file is locked")
     }
 
+    fileAsBytes, err := APIstub.GetState(ckey)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    if fileAsBytes == nil {
+        return shim.Error("The file is not exist")
+    }
+    file := catalog.File{}
+    json.Unmarshal(fileAsBytes, &file)
+
+    if file.Owner != uname {
+        return shim.Error("Permission denied")
+    }
+
+    file.Hash = args[3]
+    file.Magnet = args[4]
+    if len(args) > 6 {
+        file.Size, err = strconv.ParseInt(args[6], 10, 64)
+        if err != nil {
+            return shim.Error("size must be an integer")
+        }
+    }
+    // the info of the previous version must not outlive its magnet
+    file.Info = nil
+    if len(args) > 7 && args[7] != "" {
+        file.Info = []byte(args[7])
+    }
+    file.Cipher = ""
+    if len(args) > 8 {
+        file.Cipher = args[8]
+    }
+    // files created before versions were counted are version 1
+    if file.Version == 0 {
+        file.Version = 1
+    }
+    file.Version++
+    fileAsBytes, _ = json.Marshal(file)
+    APIstub.PutState(ckey, fileAsBytes)
+
+    APIstub.SetEvent("updateFile", fileAsBytes)
+    return shim.Success([]byte(uname))
+}
+
+
+/*
+ * updateFileMeta function: replace summary and access policy of a file without changing its content.
+ * must provide complete composite key
+ */
+func (s *SmartContract) updateFileMeta(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
+    if len(args) != 5 {
+        return shim.Error("Incorrect number of arguments. Expecting 3 keys, summary and access")
+    }
+
+    uname, err := s.testCertificate(APIstub, nil)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+
+    ckey, err := APIstub.CreateCompositeKey(catalog.FileType, []string{args[0], args[1], args[2]})
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    fileAsBytes, err := APIstub.GetState(ckey)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    if fileAsBytes == nil {
+        return shim.Error("The file is not exist")
+    }
+    file := catalog.File{}
+    json.Unmarshal(fileAsBytes, &file)
+
+    if file.Owner != uname {
+        return shim.Error("Permission denied")
+    }
+
+    file.Summary = args[3]
+    file.Access = args[4]
+    fileAsBytes, _ = json.Marshal(file)
+    APIstub.PutState(ckey, fileAsBytes)
+
+    APIstub.SetEvent("updateFile", fileAsBytes)
+    return shim.Success([]byte(uname))
+}
+
+
+/*
+ * changeFileOwner function: change owner of a file. must provide complete composite key
+ */
+func (s *SmartContract) changeFileOwner(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
+    if len(args) != 4 {
+        return shim.Error("Incorrect number of arguments. Expecting 3 keys and 1 new owner")
+    }
+
+    uname, err := s.testCertificate(APIstub, nil)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+
     // create composite key
     keys := []string{args[0], args[1], args[2]}
-    ckey, err := APIstub.CreateCompositeKey("File", keys)
+    ckey, err := APIstub.CreateCompositeKey(catalog.FileType, keys)
     if err != nil {
         return shim.Error(err.Error())
     }
+
+    // test Locktime
+    timeflag := s.testLocktime(APIstub, []string{ckey})
+    if timeflag >= 2 {
+        return shim.Error("The file is locked")
+    }
+
     //query the File
     fileAsBytes, _ := APIstub.GetState(ckey)
-    file := File{}
+    file := catalog.File{}
     json.Unmarshal(fileAsBytes, &file)
 
     if file.Owner != uname {
@@ -214,12 +389,6 @@ func (s *SmartContract) deleteFile(APIstub shim.ChaincodeStubInterface, args []s
         return shim.Error("Incorrect number of arguments. Expecting 3 keys")
     }
 
-    // test Locktime
-    timeflag := s.testLocktime(APIstub, []string{args[0], args[1], args[2]})
-    if timeflag >= 2 {
-        return shim.Error("The file is locked")
-    }
-
     uname, err := s.testCertificate(APIstub, nil)
     if err != nil {
         return shim.Error(err.Error())
@@ -230,10 +399,16 @@ func (s *SmartContract) deleteFile(APIstub shim.ChaincodeStubInterface, args []s
 
     // create composite key
     keys := []string{args[0], args[1], args[2]}
-    ckey, err := APIstub.CreateCompositeKey("File", keys)
+    ckey, err := APIstub.CreateCompositeKey(catalog.FileType, keys)
     if err != nil {
         return shim.Error(err.Error())
     }
+
+    // test Locktime
+    timeflag := s.testLocktime(APIstub, []string{ckey})
+    if timeflag >= 2 {
+        return shim.Error("The file is locked")
+    }
     //query the File
     err = APIstub.DelState(ckey)
     if err != nil {
@@ -274,7 +449,7 @@ func (s *SmartContract) addLocktime(APIstub shim.ChaincodeStubInterface, args []
 
     //query the File
     fileAsBytes, _ := APIstub.GetState(args[0])
-    file := File{}
+    file := catalog.File{}
     json.Unmarshal(fileAsBytes, &file)
 
     if file.Owner != uname {
@@ -298,7 +473,7 @@ func (s *SmartContract) testLocktime(APIstub shim.ChaincodeStubInterface, args [
 
     //query the File
     fileAsBytes, _ := APIstub.GetState(args[0])
-    file := File{}
+    file := catalog.File{}
     json.Unmarshal(fileAsBytes, &file)
 
     timestamp, err := APIstub.GetTxTimestamp()
@@ -335,7 +510,7 @@ func (s *SmartContract) externalTestLocktime(APIstub shim.ChaincodeStubInterface
 
     //query the File
     fileAsBytes, _ := APIstub.GetState(args[0])
-    file := File{}
+    file := catalog.File{}
     json.Unmarshal(fileAsBytes, &file)
 
     timestamp, err := APIstub.GetTxTimestamp()
@@ -360,6 +535,39 @@ func (s *SmartContract) externalTestLocktime(APIstub shim.ChaincodeStubInterface
 }
 
 
+/*
+ * externalTestAccess function: called by exchange chaincode, fails unless the access policy
+ * of the file allows the creator of the transaction, or the user given, to receive its key
+ */
+func (s *SmartContract) externalTestAccess(APIstub shim.ChaincodeStubInterface, args []string) sc.Response {
+
+    if len(args) != 1 && len(args) != 2 {
+        return shim.Error("Incorrect number of arguments. Expecting file key and optional user, the caller by default")
+    }
+
+    uname, err := s.testCertificate(APIstub, nil)
+    if err != nil {
+        return shim.Error(err.Error())
+    }
+    // keyExchange checks the requester again when a key is delivered
+    if len(args) == 2 {
+        uname = args[1]
+    }
+
+    fileAsBytes, _ := APIstub.GetState(args[0])
+    if fileAsBytes == nil {
+        return shim.Error("The file is not exist")
+    }
+    file := catalog.File{}
+    json.Unmarshal(fileAsBytes, &file)
+
+    if file.Allows(uname) {
+        return shim.Success(nil)
+    }
+    return shim.Error("Permission denied by the access policy of the file")
+}
+
+
 func (s *SmartContract) testCertificate(stub shim.ChaincodeStubInterface, args []string ) (string, error) {
     creatorByte, _ := stub.GetCreator()
     certStart := bytes.IndexAny(creatorByte, "-----BEGIN")
@@ -390,7 +598,7 @@ func (s *SmartContract) getAllMagnet(stub shim.ChaincodeStubInterface) sc.Respon
 
     var m=make([][]byte,0)
     for keysIter.HasNext() {
-        file := File{}
+        file := catalog.File{}
         value, iterErr := keysIter.Next()
         if iterErr != nil {
             return shim.Error(fmt.Sprintf("keys operation failed. Error accessing state: %s", err))